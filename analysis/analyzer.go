@@ -0,0 +1,593 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package analysis provides a static analyzer which checks the usage of the di package in client code. It finds the
+// string literal keys passed to the container and builder methods and the inject struct tags, and cross-checks them
+// against the definitions discoverable in the analyzed program.
+package analysis
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// diPath is the import path of the di package.
+const diPath = "github.com/golossus/di"
+
+// locatorPrefix is the prefix of the inject struct tags which inject a locator, as in the di package.
+const locatorPrefix = "locator:"
+
+// Analyzer reports the keys used with the di package which are not defined, the unexported struct fields with inject
+// tags and the type assertions of retrieved services which don't match the type of their definition.
+//
+// Definitions are discovered from the calls to the builder setters and from the di.Binding literals with constant keys.
+// As packages using a key usually don't import the package defining it, missing keys and tags are only reported on main
+// packages, where the definitions and usages of the whole program are visible. Those of imported packages are reported
+// on the package clause with the position where they are used. Missing keys are only reported when some definition is
+// discoverable and all of them have constant keys, because otherwise the analyzer can't know the whole set of keys.
+var Analyzer = &analysis.Analyzer{
+	Name:      "di",
+	Doc:       "check the keys, inject tags and type assertions of services used with github.com/golossus/di",
+	Run:       run,
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	FactTypes: []analysis.Fact{new(Definitions), new(Usages)},
+}
+
+// keySetters are the builder methods defining a service on the key given as their first argument.
+var keySetters = map[string]bool{
+	"SetValue":      true,
+	"SetFactory":    true,
+	"SetInjectable": true,
+	"SetAlias":      true,
+	"SetChild":      true,
+	"SetSynthetic":  true,
+	"SetLocator":    true,
+}
+
+// Definitions is the fact exported for each package with the services it defines. Keys maps each key to the type of
+// its service, or an empty string if unknown. Aliases maps alias keys to their aliased keys. Dynamic is true if some
+// definition has a non constant key.
+type Definitions struct {
+	Keys    map[string]string
+	Aliases map[string]string
+	Tags    map[string]bool
+	Dynamic bool
+}
+
+// AFact marks Definitions as an analysis fact.
+func (*Definitions) AFact() {}
+
+// String returns the sorted keys of the definitions.
+func (d *Definitions) String() string {
+	keys := make([]string, 0, len(d.Keys))
+	for k := range d.Keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return "definitions(" + strings.Join(keys, ", ") + ")"
+}
+
+// Usage is a key, or a tag if Tag is true, used by a package at the given position.
+type Usage struct {
+	Name string
+	Tag  bool
+	Pos  string
+	pos  token.Pos
+}
+
+// Usages is the fact exported for each non main package with the keys and tags it uses, so they are checked on the main
+// packages importing it.
+type Usages struct {
+	List []Usage
+}
+
+// AFact marks Usages as an analysis fact.
+func (*Usages) AFact() {}
+
+// String returns the sorted keys and tags used, the latter prefixed by "#".
+func (u *Usages) String() string {
+	names := make([]string, 0, len(u.List))
+	seen := make(map[string]bool)
+	for _, use := range u.List {
+		name := use.Name
+		if use.Tag {
+			name = "#" + name
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return "usages(" + strings.Join(names, ", ") + ")"
+}
+
+// scope holds the definitions known by the analyzed package, both its own and the ones of its dependencies, and the
+// keys and tags it uses. Local types are kept to check type assertions against interfaces.
+type scope struct {
+	defs   *Definitions
+	local  *Definitions
+	types  map[string]types.Type
+	usages []Usage
+}
+
+// run collects the definitions of the package, exports them as a fact and checks the usages.
+func run(pass *analysis.Pass) (interface{}, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	s := &scope{
+		defs:  newDefinitions(),
+		local: newDefinitions(),
+		types: make(map[string]types.Type),
+	}
+	s.collect(pass, ins)
+
+	if len(s.local.Keys) > 0 || s.local.Dynamic {
+		pass.ExportPackageFact(s.local)
+	}
+	for _, f := range pass.AllPackageFacts() {
+		if d, ok := f.Fact.(*Definitions); ok {
+			s.defs.merge(d)
+		}
+	}
+	s.defs.merge(s.local)
+
+	s.check(pass, ins)
+
+	if pass.Pkg.Name() != "main" {
+		if len(s.usages) > 0 {
+			pass.ExportPackageFact(&Usages{List: s.usages})
+		}
+		return nil, nil
+	}
+
+	s.report(pass)
+
+	return nil, nil
+}
+
+// report reports the keys and tags used by the main package and by the packages it imports which are not defined.
+func (s *scope) report(pass *analysis.Pass) {
+	if !s.complete() {
+		return
+	}
+
+	for _, u := range s.usages {
+		if s.missing(u) {
+			pass.Reportf(u.pos, "%s", u.message())
+		}
+	}
+
+	imported := make([]Usage, 0)
+	for _, f := range pass.AllPackageFacts() {
+		if u, ok := f.Fact.(*Usages); ok {
+			imported = append(imported, u.List...)
+		}
+	}
+	sort.Slice(imported, func(i, j int) bool {
+		if imported[i].Pos != imported[j].Pos {
+			return imported[i].Pos < imported[j].Pos
+		}
+		return imported[i].Name < imported[j].Name
+	})
+
+	for _, u := range imported {
+		if s.missing(u) {
+			pass.Reportf(pass.Files[0].Name.Pos(), "%s, used at %s", u.message(), u.Pos)
+		}
+	}
+}
+
+// missing returns if the key or tag of the usage is not defined.
+func (s *scope) missing(u Usage) bool {
+	if u.Tag {
+		return !s.defs.Tags[u.Name]
+	}
+
+	_, ok := s.defs.Keys[u.Name]
+	return !ok
+}
+
+// message returns the message reported if the key or tag is not defined.
+func (u Usage) message() string {
+	if u.Tag {
+		return "no service is tagged with '" + u.Name + "'"
+	}
+
+	return "service with key '" + u.Name + "' is not defined"
+}
+
+// use records the usage of a key, or a tag, at the position of the given expression.
+func (s *scope) use(pass *analysis.Pass, expr ast.Expr, name string, tag bool) {
+	pos := expr.Pos()
+	s.usages = append(s.usages, Usage{Name: name, Tag: tag, Pos: pass.Fset.Position(pos).String(), pos: pos})
+}
+
+// newDefinitions returns empty Definitions.
+func newDefinitions() *Definitions {
+	return &Definitions{
+		Keys:    make(map[string]string),
+		Aliases: make(map[string]string),
+		Tags:    make(map[string]bool),
+	}
+}
+
+// merge adds the given definitions to the current ones.
+func (d *Definitions) merge(o *Definitions) {
+	for k, t := range o.Keys {
+		d.Keys[k] = t
+	}
+	for k, a := range o.Aliases {
+		d.Aliases[k] = a
+	}
+	for t := range o.Tags {
+		d.Tags[t] = true
+	}
+	d.Dynamic = d.Dynamic || o.Dynamic
+}
+
+// define adds a definition from a raw key with tags, and the type of its service if known.
+func (s *scope) define(raw string, typ types.Type) string {
+	key, tags := parseKey(raw)
+	s.local.Keys[key] = ""
+	if typ != nil {
+		s.local.Keys[key] = typ.String()
+		s.types[key] = typ
+	}
+	for t := range tags {
+		s.local.Tags[t] = true
+	}
+
+	return key
+}
+
+// collect finds the definitions of the package in the builder setter calls and the di.Binding literals.
+func (s *scope) collect(pass *analysis.Pass, ins *inspector.Inspector) {
+	filter := []ast.Node{(*ast.CallExpr)(nil), (*ast.CompositeLit)(nil)}
+	ins.Preorder(filter, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			name := diMethod(pass, n)
+			if name == "Alias" || name == "Tag" {
+				s.collectFluent(pass, n, name)
+				return
+			}
+			if !keySetters[name] || len(n.Args) == 0 {
+				return
+			}
+			raw, ok := stringValue(pass, n.Args[0])
+			if !ok {
+				s.local.Dynamic = true
+				return
+			}
+
+			var typ types.Type
+			if (name == "SetValue" || name == "SetInjectable") && len(n.Args) > 1 {
+				typ = concreteType(pass, n.Args[1])
+			}
+			key := s.define(raw, typ)
+
+			if name == "SetAlias" && len(n.Args) > 1 {
+				if target, ok := stringValue(pass, n.Args[1]); ok {
+					s.local.Aliases[key] = target
+				}
+			}
+
+		case *ast.CompositeLit:
+			if !isDiType(pass.TypesInfo.TypeOf(n), "Binding") {
+				return
+			}
+			for _, e := range n.Elts {
+				kv, ok := e.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if id, ok := kv.Key.(*ast.Ident); !ok || id.Name != "Key" {
+					continue
+				}
+				if raw, ok := stringValue(pass, kv.Value); ok {
+					s.define(raw, nil)
+				} else {
+					s.local.Dynamic = true
+				}
+			}
+		}
+	})
+}
+
+// collectFluent finds the aliases and tags set by the chained calls on the definitions returned by the builder setters,
+// e.g. b.SetValue("key", 1).Alias("alias").Tag("tag").
+func (s *scope) collectFluent(pass *analysis.Pass, call *ast.CallExpr, name string) {
+	if name == "Tag" {
+		if len(call.Args) > 0 {
+			if tag, ok := stringValue(pass, call.Args[0]); ok {
+				s.local.Tags[tag] = true
+			}
+		}
+		return
+	}
+
+	target, known := fluentKey(pass, call)
+	for _, arg := range call.Args {
+		raw, ok := stringValue(pass, arg)
+		if !ok {
+			s.local.Dynamic = true
+			continue
+		}
+		key := s.define(raw, nil)
+		if known {
+			s.local.Aliases[key] = target
+		}
+	}
+}
+
+// fluentKey returns the key of the definition configured by a chained call, found in the setter call starting the
+// chain, if it is a constant.
+func fluentKey(pass *analysis.Pass, call *ast.CallExpr) (string, bool) {
+	for {
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return "", false
+		}
+		if call, ok = sel.X.(*ast.CallExpr); !ok {
+			return "", false
+		}
+
+		name := diMethod(pass, call)
+		if keySetters[name] && len(call.Args) > 0 {
+			raw, ok := stringValue(pass, call.Args[0])
+			if !ok {
+				return "", false
+			}
+			key, _ := parseKey(raw)
+			return key, true
+		}
+	}
+}
+
+// check records the keys and tags used, and reports the invalid inject struct tags and the mismatching type
+// assertions.
+func (s *scope) check(pass *analysis.Pass, ins *inspector.Inspector) {
+	filter := []ast.Node{(*ast.CallExpr)(nil), (*ast.TypeAssertExpr)(nil), (*ast.StructType)(nil)}
+	ins.Preorder(filter, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			s.checkCall(pass, n)
+		case *ast.TypeAssertExpr:
+			s.checkAssertion(pass, n)
+		case *ast.StructType:
+			s.checkStruct(pass, n)
+		}
+	})
+}
+
+// complete returns if the whole set of keys is known, so missing keys can be reported.
+func (s *scope) complete() bool {
+	return len(s.defs.Keys) > 0 && !s.defs.Dynamic
+}
+
+// checkCall records the keys passed to Get, SetAlias and SetChild, and the tags passed to GetTaggedBy.
+func (s *scope) checkCall(pass *analysis.Pass, call *ast.CallExpr) {
+	var arg int
+	switch diMethod(pass, call) {
+	case "Get":
+		arg = 0
+	case "SetAlias", "SetChild":
+		arg = 1
+	case "GetTaggedBy":
+		if len(call.Args) == 0 {
+			return
+		}
+		if tag, ok := stringValue(pass, call.Args[0]); ok {
+			s.use(pass, call.Args[0], tag, true)
+		}
+		return
+	default:
+		return
+	}
+
+	if len(call.Args) <= arg {
+		return
+	}
+	if key, ok := stringValue(pass, call.Args[arg]); ok {
+		s.use(pass, call.Args[arg], key, false)
+	}
+}
+
+// checkStruct reports the unexported fields with inject tags and records the keys of the inject tags, including the
+// keys and tags whitelisted in locators.
+func (s *scope) checkStruct(pass *analysis.Pass, st *ast.StructType) {
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		raw, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			continue
+		}
+		key, ok := reflect.StructTag(raw).Lookup("inject")
+		if !ok {
+			continue
+		}
+
+		for _, name := range f.Names {
+			if !name.IsExported() {
+				pass.Reportf(name.Pos(), "unexported field %s can not be injected", name.Name)
+			}
+		}
+
+		if !strings.HasPrefix(key, locatorPrefix) {
+			s.use(pass, f.Tag, key, false)
+			continue
+		}
+
+		for _, e := range strings.Split(strings.TrimPrefix(key, locatorPrefix), ",") {
+			k, tags := parseKey(e)
+			if k != "" {
+				s.use(pass, f.Tag, k, false)
+			}
+			for t := range tags {
+				s.use(pass, f.Tag, t, true)
+			}
+		}
+	}
+}
+
+// checkAssertion reports the type assertions of services retrieved with Get whose type doesn't match the type of the
+// value or injectable defined on the key.
+func (s *scope) checkAssertion(pass *analysis.Pass, ta *ast.TypeAssertExpr) {
+	call, ok := ast.Unparen(ta.X).(*ast.CallExpr)
+	if !ok || ta.Type == nil || diMethod(pass, call) != "Get" || len(call.Args) == 0 {
+		return
+	}
+	key, ok := stringValue(pass, call.Args[0])
+	if !ok {
+		return
+	}
+
+	for i := 0; i < len(s.defs.Aliases) && s.defs.Aliases[key] != ""; i++ {
+		key = s.defs.Aliases[key]
+	}
+
+	defined := s.defs.Keys[key]
+	asserted := pass.TypesInfo.TypeOf(ta.Type)
+	if defined == "" || asserted == nil {
+		return
+	}
+
+	if iface, ok := asserted.Underlying().(*types.Interface); ok {
+		if typ, ok := s.types[key]; ok && !types.Implements(typ, iface) {
+			pass.Reportf(ta.Pos(), "service with key '%s' of type %s does not implement %s", key, defined, asserted)
+		}
+		return
+	}
+
+	if asserted.String() != defined {
+		pass.Reportf(ta.Pos(), "service with key '%s' is of type %s, not %s", key, defined, asserted)
+	}
+}
+
+// diMethod returns the name of the di method or function called, or an empty string if the call is not to the di
+// package.
+func diMethod(pass *analysis.Pass, call *ast.CallExpr) string {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != diPath {
+		return ""
+	}
+
+	return fn.Name()
+}
+
+// isDiType returns if the given type is the named type of the di package.
+func isDiType(t types.Type, name string) bool {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+
+	return obj.Pkg() != nil && obj.Pkg().Path() == diPath && obj.Name() == name
+}
+
+// stringValue returns the value of a constant string expression.
+func stringValue(pass *analysis.Pass, expr ast.Expr) (string, bool) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+
+	return constant.StringVal(tv.Value), true
+}
+
+// concreteType returns the type of the given expression unless it's an interface, which would hide the dynamic type
+// of the service, or an untyped constant.
+func concreteType(pass *analysis.Pass, expr ast.Expr) types.Type {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Type == nil || types.IsInterface(tv.Type) {
+		return nil
+	}
+
+	return types.Default(tv.Type)
+}
+
+// keyEscapable are the characters which can be escaped with a backslash outside quotes, as in the di package.
+const keyEscapable = "#=\"\\ \t"
+
+// parseKey returns the key and tag names of a raw key using the tag syntax of the di package, e.g. "key #tag=value",
+// including quoted parts and escaped characters. It follows the key scanner of the di package, both being tested
+// against the cases of its testdata/keys.json, but malformed keys are parsed on a best-effort basis, as they are
+// reported by the di package itself: unterminated quotes extend to the end of the key and empty tag names are skipped.
+func parseKey(raw string) (string, map[string]bool) {
+	var key string
+	tags := make(map[string]bool)
+	var buf []rune
+	var quoted []bool
+	write := func(r rune, q bool) {
+		buf, quoted = append(buf, r), append(quoted, q)
+	}
+	flush := func() string {
+		start, end := 0, len(buf)
+		for start < end && !quoted[start] && unicode.IsSpace(buf[start]) {
+			start++
+		}
+		for end > start && !quoted[end-1] && unicode.IsSpace(buf[end-1]) {
+			end--
+		}
+		text := string(buf[start:end])
+		buf, quoted = buf[:0], quoted[:0]
+		return text
+	}
+
+	runes := []rune(raw)
+	inKey, inTag := true, false
+	end := func() {
+		text := flush()
+		switch {
+		case inKey:
+			key = text
+		case inTag && text != "":
+			tags[text] = true
+		}
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune(keyEscapable, runes[i+1]):
+			i++
+			write(runes[i], true)
+		case r == '"':
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				write(runes[i], true)
+			}
+		case r == '#':
+			end()
+			inKey, inTag = false, true
+		case r == '=' && inTag:
+			end()
+			inTag = false
+		default:
+			write(r, false)
+		}
+	}
+	end()
+
+	return key, tags
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package analysis
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a", "b", "c", "d", "e", "m")
+}
+
+func TestParseKey(t *testing.T) {
+	src, err := os.ReadFile("../testdata/keys.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests []struct {
+		Raw  string              `json:"raw"`
+		Key  string              `json:"key"`
+		Tags map[string][]string `json:"tags"`
+	}
+	if err := json.Unmarshal(src, &tests); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range tests {
+		t.Run(data.Raw, func(t *testing.T) {
+			key, tags := parseKey(data.Raw)
+			if key != data.Key {
+				t.Errorf("expected key '%s', got '%s'", data.Key, key)
+			}
+			expected := make(map[string]bool)
+			for tag := range data.Tags {
+				expected[tag] = true
+			}
+			if !reflect.DeepEqual(expected, tags) {
+				t.Errorf("expected tags %v, got %v", expected, tags)
+			}
+		})
+	}
+}
+
+func TestParseKeyMalformed(t *testing.T) {
+	key, tags := parseKey(`key #a ## b #=x #c="y #d`)
+	if key != "key" {
+		t.Errorf("expected key 'key', got '%s'", key)
+	}
+	if !reflect.DeepEqual(map[string]bool{"a": true, "b": true, "c": true}, tags) {
+		t.Errorf("expected tags a, b and c, got %v", tags)
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Command dilint runs the di analyzer, which checks the usage of github.com/golossus/di, on the given packages:
+//
+//	go run github.com/golossus/di/analysis/cmd/dilint ./...
+package main
+
+import (
+	"github.com/golossus/di/analysis"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analysis.Analyzer)
+}
@@ -0,0 +1,43 @@
+package a // want package:`definitions\(email.from, email.listener, email.mailer, email.port, mailer.deafult, mailer.default\)` package:`usages\(#listener, #listeners, #missing, email.from, email.listener, email.mailer, email.mailr, email.to, mailer.deafult, mailer.default, mailer.defautl\)`
+
+import (
+	"fmt"
+
+	"github.com/golossus/di"
+)
+
+type Stringer interface {
+	String() string
+}
+
+type Mailer struct {
+	From   string       `inject:"email.from"`
+	To     string       `inject:"email.to"`
+	sender string       `inject:"email.from"` // want `unexported field sender can not be injected`
+	Lists  di.Container `inject:"locator:email.from,#listener,#missing"`
+}
+
+func Build() *di.Binding {
+	b := di.NewContainerBuilder()
+	b.SetValue("email.from", "from@email.com")
+	b.SetInjectable("email.mailer #shared", &Mailer{})
+	b.SetFactory("email.listener #listener=email", func(c di.Container) interface{} {
+		return c.Get("email.mailer").(*Mailer)
+	})
+	b.SetAlias("mailer.default", "email.mailer")
+	b.SetAlias("mailer.deafult", "email.mailr")
+	b.SetAll(di.Binding{Key: "email.port #value", Target: 25})
+
+	c := b.GetContainer()
+	_ = c.Get("email.from").(string)
+	_ = c.Get("email.from").(int)              // want `service with key 'email.from' is of type string, not int`
+	_ = c.Get("mailer.default").(Mailer)       // want `service with key 'email.mailer' is of type \*a.Mailer, not a.Mailer`
+	_ = c.Get("mailer.default").(fmt.Stringer) // want `service with key 'email.mailer' of type \*a.Mailer does not implement fmt.Stringer`
+	_ = c.Get("email.listener").(Stringer)
+	_ = c.Get("mailer.deafult")
+	_ = c.Get("mailer.defautl")
+	_ = c.GetTaggedBy("listener")
+	_ = c.GetTaggedBy("listeners")
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package b // want package:`usages\(email.form, email.from\)`
+
+import (
+	"a"
+
+	"github.com/golossus/di"
+)
+
+var _ = a.Build
+
+func Handle(c di.Container) {
+	_ = c.Get("email.from").(string)
+	_ = c.Get("email.form")
+}
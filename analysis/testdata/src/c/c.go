@@ -0,0 +1,12 @@
+package c // want package:`definitions\(\)` package:`usages\(unknown\)`
+
+import (
+	"github.com/golossus/di"
+)
+
+func Handle(c di.Container, key string) {
+	b := di.NewContainerBuilder()
+	b.SetValue(key, 1)
+
+	_ = c.Get("unknown")
+}
@@ -0,0 +1,18 @@
+package d // want package:`definitions\(email.from, email.sender, from, sender\)` package:`usages\(#listener, #param, from, sender, sendr\)`
+
+import "github.com/golossus/di"
+
+func Build() {
+	b := di.NewContainerBuilder()
+	b.SetTagComparator(di.PriorityAsc)
+	b.SetValue("email.from", "from@email.com").Tag("param").Alias("from")
+	b.SetFactory("email.sender", nil).Tag("listener", "email").Alias("sender")
+
+	c := b.GetContainer()
+	_ = c.Get("from").(string)
+	_ = c.Get("from").(int) // want `service with key 'email.from' is of type string, not int`
+	_ = c.Get("sender")
+	_ = c.Get("sendr")
+	_ = c.GetTaggedBy("param")
+	_ = c.GetTaggedBy("listener")
+}
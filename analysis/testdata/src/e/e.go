@@ -0,0 +1,15 @@
+package e // want package:`definitions\(email.queue\)` package:`usages\(email.mailer, email.queeu, email.queue\)`
+
+import "github.com/golossus/di"
+
+func Register() {
+	b := di.NewContainerBuilder()
+	b.SetFactory("email.queue", func(c di.Container) interface{} {
+		return c.Get("email.mailer")
+	})
+}
+
+func Send(c di.Container) {
+	_ = c.Get("email.queue")
+	_ = c.Get("email.queeu")
+}
@@ -0,0 +1,78 @@
+// Package di is a stub of github.com/golossus/di with the API used by the analyzer tests.
+package di
+
+type Container interface {
+	Get(key string) interface{}
+	GetTaggedBy(tag string, values ...string) []interface{}
+}
+
+type Binding struct {
+	Key    string
+	Target interface{}
+	Tags   map[string]string
+}
+
+type Definition interface {
+	GetKey() string
+}
+
+type DefinitionBuilder interface {
+	Definition
+	Tag(tag string, values ...string) DefinitionBuilder
+	Alias(keys ...string) DefinitionBuilder
+}
+
+type ContainerBuilder interface {
+	SetValue(key string, value interface{}, tags ...map[string]string) DefinitionBuilder
+	SetFactory(key string, factory func(Container) interface{}, tags ...map[string]string) DefinitionBuilder
+	SetInjectable(key string, i interface{}, tags ...map[string]string) DefinitionBuilder
+	SetAlias(key, def string, tags ...map[string]string) DefinitionBuilder
+	SetAll(all ...Binding)
+	SetTagComparator(cmp TagComparator)
+	GetContainer() ResolvedContainer
+}
+
+type containerBuilder struct{}
+
+func NewContainerBuilder() ContainerBuilder { return &containerBuilder{} }
+
+func (c *containerBuilder) SetValue(key string, value interface{}, tags ...map[string]string) DefinitionBuilder {
+	return nil
+}
+
+func (c *containerBuilder) SetFactory(key string, factory func(Container) interface{}, tags ...map[string]string) DefinitionBuilder {
+	return nil
+}
+
+func (c *containerBuilder) SetInjectable(key string, i interface{}, tags ...map[string]string) DefinitionBuilder {
+	return nil
+}
+
+func (c *containerBuilder) SetAlias(key, def string, tags ...map[string]string) DefinitionBuilder {
+	return nil
+}
+
+func (c *containerBuilder) SetAll(all ...Binding) {}
+
+type TaggedService struct {
+	Key      string
+	Priority int16
+}
+
+type TagComparator func(a, b TaggedService) int
+
+func PriorityAsc(a, b TaggedService) int { return int(a.Priority) - int(b.Priority) }
+
+func (c *containerBuilder) SetTagComparator(cmp TagComparator) {}
+
+func (c *containerBuilder) GetContainer() ResolvedContainer { return &container{} }
+
+type ResolvedContainer interface {
+	Container
+}
+
+type container struct{}
+
+func (c *container) Get(key string) interface{} { return nil }
+
+func (c *container) GetTaggedBy(tag string, values ...string) []interface{} { return nil }
@@ -0,0 +1,21 @@
+package main // want `service with key 'email.to' is not defined, used at .*a.go:15:22` `no service is tagged with 'missing', used at .*a.go:17:22` `service with key 'email.mailr' is not defined, used at .*a.go:28:31` `service with key 'mailer.defautl' is not defined, used at .*a.go:38:12` `no service is tagged with 'listeners', used at .*a.go:40:20` `service with key 'email.form' is not defined, used at .*b.go:13:12` `service with key 'sendr' is not defined, used at .*d.go:15:12` `service with key 'email.queeu' is not defined, used at .*e.go:14:12`
+
+import (
+	"a"
+	"b"
+	"d"
+	"e"
+
+	"github.com/golossus/di"
+)
+
+func main() {
+	a.Build()
+	d.Build()
+
+	c := di.NewContainerBuilder().GetContainer()
+	b.Handle(c)
+	e.Send(c)
+	_ = c.Get("email.queue")
+	_ = c.Get("email.queue.typo") // want `service with key 'email.queue.typo' is not defined`
+}
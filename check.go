@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// checker is an Observer recording the chain of services being built when the first build error is produced.
+type checker struct {
+	chains [][]string
+	failed []string
+}
+
+// OnBeforeBuild pushes the chain of services being built.
+func (ch *checker) OnBeforeBuild(_ string, chain []string) {
+	ch.chains = append(ch.chains, chain)
+}
+
+// OnAfterBuild pops the chain of services being built and records it if it's the innermost one failing.
+func (ch *checker) OnAfterBuild(_ string, _ interface{}, _ time.Duration, err error) {
+	if err != nil && ch.failed == nil {
+		ch.failed = ch.chains[len(ch.chains)-1]
+	}
+	ch.chains = ch.chains[:len(ch.chains)-1]
+}
+
+// OnCacheHit does nothing.
+func (ch *checker) OnCacheHit(string) {}
+
+// Check builds all the services of the container, including the private ones, in key order to discover unexpected
+// panics before they happen on runtime. Abstract services and synthetic services not provided yet are skipped, as well
+// as the services failing because they depend on the latter, since they can only be built once provided. Every
+// panic is recovered and returned as a BuildError with the chain of services being built when it was produced, all
+// of them aggregated in BuildErrors sorted by key. Built shared instances are preserved. Services retrieved concurrently
+// from other goroutines are not checked, as the checker only observes the services built by Check.
+func (c *container) Check() error {
+	keys := make([]string, 0, len(c.builder.definitions))
+	for k, d := range c.builder.definitions {
+		if d.Abstract {
+			continue
+		}
+		if c.unprovided(k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ch := &checker{}
+	u := c.scope(ch).unseal()
+	errs := make(BuildErrors, 0)
+	for _, k := range keys {
+		ch.chains, ch.failed = nil, nil
+		if err := u.build(k); err != nil {
+			err.Chain = ch.failed
+			if err.Chain == nil {
+				err.Chain = []string{k}
+			}
+			if c.unprovided(err.Chain[len(err.Chain)-1]) {
+				continue
+			}
+			errs = append(errs, *err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// unprovided returns if the service on the given key is synthetic and its value has not been provided yet.
+func (c *container) unprovided(key string) bool {
+	def := c.definition(key)
+	if def == nil || def.Kind != TagSynthetic {
+		return false
+	}
+
+	_, ok := c.synthetics.Load(key)
+	return !ok
+}
+
+// MustBuild checks the container building all its services at once and panics with the errors found, if any. If given
+// false as parameter, singleton services instances will be preserved. On the contrary, a "dry" build will be executed
+// and all built services will be removed to have a fresh container.
+func (c *container) MustBuild(dry bool) {
+	if err := c.Check(); err != nil {
+		panic(fmt.Sprintf("%s", err))
+	}
+
+	if dry {
+		c.Reset()
+	}
+}
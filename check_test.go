@@ -0,0 +1,107 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainer_Check(t *testing.T) {
+	t.Run("returns nil if all services are built", func(t *testing.T) {
+		b := newContainerBuilder()
+		b.SetValue("s1", 1)
+		b.SetFactory("s2 #shared", func(c Container) interface{} {
+			return c.Get("s1").(int) + 1
+		})
+		b.SetFactory("s3 #abstract", func(c Container) interface{} {
+			panic("not built")
+		})
+		b.SetSynthetic("s4", nil)
+		c := b.resolve()
+
+		assert.Nil(t, c.Check())
+		assert.Len(t, c.instances, 1)
+	})
+
+	t.Run("builds private services", func(t *testing.T) {
+		built := false
+		b := NewContainerBuilder()
+		b.SetFactory("s1 #private", func(c Container) interface{} {
+			built = true
+			return 1
+		})
+		c := b.GetContainer()
+
+		assert.Nil(t, c.Check())
+		assert.True(t, built)
+	})
+
+	t.Run("returns every failing key with its chain sorted by key", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetFactory("s1", func(c Container) interface{} {
+			return c.Get("s2")
+		})
+		b.SetFactory("s2 #private", func(c Container) interface{} {
+			return c.Get("s3").(int)
+		})
+		b.SetValue("s3", "I'm a string!")
+		b.SetFactory("s0", func(c Container) interface{} {
+			return c.Get("missing")
+		})
+		c := b.GetContainer()
+
+		err := c.Check()
+
+		errs, ok := err.(BuildErrors)
+		assert.True(t, ok)
+		assert.Len(t, errs, 3)
+		assert.Equal(t, "s0", errs[0].Key)
+		assert.Equal(t, []string{"s0"}, errs[0].Chain)
+		assert.Equal(t, "service with key 'missing' not found", errs[0].Err.Error())
+		assert.Equal(t, "s1", errs[1].Key)
+		assert.Equal(t, []string{"s1", "s2"}, errs[1].Chain)
+		assert.Equal(t, "s2", errs[2].Key)
+		assert.Equal(t, []string{"s2"}, errs[2].Chain)
+		assert.Contains(t, errs[1].Error(), "service with key 's1' failed to build at s1 -> s2: ")
+	})
+
+	t.Run("skips services depending on synthetic services not provided", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetSynthetic("req", nil)
+		b.SetFactory("h", func(c Container) interface{} {
+			return c.Get("req")
+		})
+		b.SetFactory("m", func(c Container) interface{} {
+			return []interface{}{c.Get("h"), c.Get("missing")}
+		})
+		c := b.GetContainer()
+
+		assert.Nil(t, c.Check())
+		assert.NotPanics(t, func() {
+			c.MustBuild(true)
+		})
+
+		c.Provide("req", "r")
+		err := c.Check()
+
+		errs, ok := err.(BuildErrors)
+		assert.True(t, ok)
+		assert.Len(t, errs, 1)
+		assert.Equal(t, "m", errs[0].Key)
+		assert.Equal(t, "service with key 'missing' not found", errs[0].Err.Error())
+	})
+
+	t.Run("preserves observers", func(t *testing.T) {
+		b := newContainerBuilder()
+		b.SetValue("s1", 1)
+		c := b.resolve()
+		c.AddObserver(LogObserver(func(string, map[string]interface{}) {}))
+
+		assert.Nil(t, c.Check())
+		assert.Len(t, c.notified(), 1)
+	})
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This is the list of reserved tags used to declare conditional definitions. Conditions are evaluated once all the
+// providers have been run, so the definition is kept or removed from the builder depending on the result.
+const (
+	TagWhen      = "when"
+	TagIfMissing = "if-missing"
+	TagIfPresent = "if-present"
+)
+
+// conditionTags are the list of reserved tags that represent conditions of service definitions.
+var conditionTags = []string{TagWhen, TagIfMissing, TagIfPresent}
+
+// lookupEnv is the function used to read environment variables on TagWhen conditions.
+var lookupEnv = os.LookupEnv
+
+// Condition is the result of evaluating one conditional tag of a definition. A definition is kept in the container only
+// if all its conditions pass.
+type Condition struct {
+	Key    string
+	Tag    string
+	Value  string
+	Passed bool
+}
+
+// String returns a human-readable representation of the condition.
+func (c Condition) String() string {
+	result := "passed"
+	if !c.Passed {
+		result = "failed"
+	}
+	return fmt.Sprintf("%s #%s=%s %s", c.Key, c.Tag, c.Value, result)
+}
+
+// isConditional returns if the definition has some condition tag.
+func (d *definition) isConditional() bool {
+	for _, tag := range conditionTags {
+		if d.HasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateConditions evaluates the conditions of every conditional definition not evaluated yet. Definitions whose
+// conditions fail are replaced by the ones they overwrote, if any. Definitions depending on the presence of other
+// pending conditional definitions are evaluated after them. It panics if some condition is invalid or if conditions
+// reference each other.
+func (c *containerBuilder) evaluateConditions() {
+	pending := make([]string, 0)
+	for key, def := range c.definitions {
+		if def.isConditional() && def.Conditions == nil {
+			pending = append(pending, key)
+		}
+	}
+	sort.Strings(pending)
+
+	for len(pending) > 0 {
+		deferred := make([]string, 0, len(pending))
+		for _, key := range pending {
+			if c.dependsOnPending(c.definitions[key], pending, key) {
+				deferred = append(deferred, key)
+				continue
+			}
+			c.evaluateDefinition(key)
+		}
+
+		if len(deferred) == len(pending) {
+			panic(fmt.Sprintf("circular conditions found between definitions %v", deferred))
+		}
+		pending = deferred
+	}
+}
+
+// dependsOnPending returns if the presence or parameter conditions of a definition reference another pending
+// definition.
+func (c *containerBuilder) dependsOnPending(def *definition, pending []string, key string) bool {
+	refs := make([]string, 0, 3)
+	for _, tag := range []string{TagIfMissing, TagIfPresent} {
+		if ref, ok := def.Tags[tag]; ok {
+			refs = append(refs, ref)
+		}
+	}
+	if value := def.Tags[TagWhen]; strings.HasPrefix(value, "param:") {
+		name := strings.TrimPrefix(value, "param:")
+		if i := strings.Index(name, "="); i >= 0 {
+			name = strings.TrimSpace(name[:i])
+		}
+		refs = append(refs, name)
+	}
+
+	for _, ref := range refs {
+		if ref == key {
+			continue
+		}
+		for _, p := range pending {
+			if p == ref {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// evaluateDefinition evaluates all conditions of the definition on the given key and records them. If any of them
+// fails, the definition is replaced by the one it overwrote, which is evaluated in turn if it is conditional, or
+// removed if there is none.
+func (c *containerBuilder) evaluateDefinition(key string) {
+	def := c.definitions[key]
+	def.Conditions = make([]Condition, 0, len(conditionTags))
+
+	keep := true
+	for _, tag := range conditionTags {
+		value, ok := def.Tags[tag]
+		if !ok {
+			continue
+		}
+
+		passed, err := c.evaluateCondition(tag, value)
+		if err != nil {
+			panic(fmt.Sprintf("%s for key '%s'", err, key))
+		}
+
+		cond := Condition{Key: key, Tag: tag, Value: value, Passed: passed}
+		def.Conditions = append(def.Conditions, cond)
+		c.conditions = append(c.conditions, cond)
+		keep = keep && passed
+	}
+
+	if keep {
+		return
+	}
+
+	if def.Overwrites == nil {
+		delete(c.definitions, key)
+		return
+	}
+
+	c.definitions[key] = def.Overwrites
+	if def.Overwrites.isConditional() && def.Overwrites.Conditions == nil {
+		c.evaluateDefinition(key)
+	}
+}
+
+// evaluateCondition returns the result of a single condition tag with its value:
+//
+//   - TagIfPresent: "key", passes if a definition with the given key exists.
+//   - TagIfMissing: "key", passes if a definition with the given key doesn't exist.
+//   - TagWhen: "env:NAME" passes if the environment variable is not empty, "env:NAME=value" if it equals the value.
+//     Similarly, "param:key" passes if the value definition on key is true and "param:key=value" if it equals value.
+func (c *containerBuilder) evaluateCondition(tag, value string) (bool, error) {
+	switch tag {
+	case TagIfPresent:
+		return c.HasDefinition(value), nil
+	case TagIfMissing:
+		return !c.HasDefinition(value), nil
+	}
+
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return false, fmt.Errorf("%s tag value '%s' is not a valid condition", tag, value)
+	}
+
+	name, expected, compare := parts[1], "", false
+	if i := strings.Index(name, "="); i >= 0 {
+		name, expected, compare = strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:]), true
+	}
+
+	var actual string
+	switch parts[0] {
+	case "env":
+		actual, _ = lookupEnv(name)
+		if !compare {
+			return actual != "", nil
+		}
+	case "param":
+		def, ok := c.definitions[name]
+		if !ok || def.Kind != TagValue {
+			return false, fmt.Errorf("%s tag value '%s' requires a value definition", tag, value)
+		}
+		actual = fmt.Sprint(def.Factory(nil))
+		if !compare {
+			b, err := strconv.ParseBool(actual)
+			if err != nil {
+				return false, fmt.Errorf("%s tag value '%s' is not a boolean parameter", tag, value)
+			}
+			return b, nil
+		}
+	default:
+		return false, fmt.Errorf("%s tag value '%s' has an unknown source", tag, value)
+	}
+
+	return actual == expected, nil
+}
+
+// Conditions returns the result of every condition evaluated while resolving the container, so the decisions taken
+// about conditional definitions can be inspected. It returns an empty list if the container is not resolved yet.
+func (c *containerBuilder) Conditions() []Condition {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	conditions := make([]Condition, len(c.conditions))
+	copy(conditions, c.conditions)
+
+	return conditions
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestContainerBuilder_EvaluateConditions(t *testing.T) {
+	env := map[string]string{"APP_ENV": "prod", "EMPTY": ""}
+	defer func(f func(string) (string, bool)) { lookupEnv = f }(lookupEnv)
+	lookupEnv = func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	for _, data := range []struct {
+		name     string
+		key      string
+		expected bool
+	}{
+		{"keeps definition if env matches", "k #when=env:APP_ENV=prod", true},
+		{"removes definition if env does not match", "k #when=env:APP_ENV=dev", false},
+		{"keeps definition if env not empty", "k #when=env:APP_ENV", true},
+		{"removes definition if env empty", "k #when=env:EMPTY", false},
+		{"removes definition if env not found", "k #when=env:NONE", false},
+		{"keeps definition if param matches", "k #when=param:p.name=abc", true},
+		{"removes definition if param does not match", "k #when=param:p.name=xyz", false},
+		{"keeps definition if param is true", "k #when=param:p.enabled", true},
+		{"removes definition if param is false", "k #when=param:p.disabled", false},
+		{"keeps definition if other present", "k #if-present=p.name", true},
+		{"removes definition if other not present", "k #if-present=none", false},
+		{"keeps definition if other missing", "k #if-missing=none", true},
+		{"removes definition if other not missing", "k #if-missing=p.name", false},
+		{"removes definition if any condition fails", "k #if-missing=none #when=env:NONE", false},
+	} {
+		t.Run(data.name, func(t *testing.T) {
+			b := NewContainerBuilder()
+			b.SetValue("p.name", "abc")
+			b.SetValue("p.enabled", true)
+			b.SetValue("p.disabled", false)
+			b.SetFactory(data.key, dummyFactory)
+			b.GetContainer()
+
+			assert.Equal(t, data.expected, b.HasDefinition("k"))
+		})
+	}
+
+	t.Run("evaluates conditions depending on other conditional definitions first", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetFactory("cache.memory #if-missing=cache.redis", dummyFactory)
+		b.SetFactory("cache.redis #when=env:APP_ENV=prod", dummyFactory)
+		b.AddResolver(ResolverFunc(func(b ContainerBuilder) {
+			b.SetFactory("cache.file #if-missing=cache.memory", dummyFactory)
+		}))
+		b.GetContainer()
+
+		assert.True(t, b.HasDefinition("cache.redis"))
+		assert.False(t, b.HasDefinition("cache.memory"))
+		assert.True(t, b.HasDefinition("cache.file"))
+		assert.Equal(t, []Condition{
+			{Key: "cache.redis", Tag: TagWhen, Value: "env:APP_ENV=prod", Passed: true},
+			{Key: "cache.memory", Tag: TagIfMissing, Value: "cache.redis", Passed: false},
+			{Key: "cache.file", Tag: TagIfMissing, Value: "cache.memory", Passed: true},
+		}, b.Conditions())
+	})
+
+	t.Run("evaluates conditions depending on conditional parameters first", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("profiling", false)
+		b.SetValue("profiling #when=env:APP_ENV=dev", true)
+		b.SetFactory("app.profiler #when=param:profiling", dummyFactory)
+		b.SetFactory("app.tracer #when=param:profiling=false", dummyFactory)
+		b.GetContainer()
+
+		assert.False(t, b.HasDefinition("app.profiler"))
+		assert.True(t, b.HasDefinition("app.tracer"))
+		assert.Equal(t, []Condition{
+			{Key: "profiling", Tag: TagWhen, Value: "env:APP_ENV=dev", Passed: false},
+			{Key: "app.profiler", Tag: TagWhen, Value: "param:profiling", Passed: false},
+			{Key: "app.tracer", Tag: TagWhen, Value: "param:profiling=false", Passed: true},
+		}, b.Conditions())
+	})
+
+	t.Run("restores overwritten definition if condition fails", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("cache", "memory")
+		b.SetValue("logger", "stdout")
+		b.AddProvider(ProviderFunc(func(b ContainerBuilder) {
+			b.SetValue("cache #when=env:NONE", "redis")
+			b.SetValue("logger #when=env:APP_ENV=dev", "file")
+			b.SetValue("logger #when=env:APP_ENV=test", "null")
+		}))
+		c := b.GetContainer()
+
+		assert.Equal(t, "memory", c.Get("cache"))
+		assert.Equal(t, "stdout", c.Get("logger"))
+		assert.Equal(t, []Condition{
+			{Key: "cache", Tag: TagWhen, Value: "env:NONE", Passed: false},
+			{Key: "logger", Tag: TagWhen, Value: "env:APP_ENV=test", Passed: false},
+			{Key: "logger", Tag: TagWhen, Value: "env:APP_ENV=dev", Passed: false},
+		}, b.Conditions())
+	})
+
+	for _, data := range []struct {
+		name  string
+		key   string
+		error string
+	}{
+		{"panics if invalid condition", "k #when=env", "when tag value 'env' is not a valid condition for key 'k'"},
+		{"panics if unknown source", "k #when=file:x", "when tag value 'file:x' has an unknown source for key 'k'"},
+		{"panics if param is not a value", "k #when=param:f", "when tag value 'param:f' requires a value definition for key 'k'"},
+		{"panics if param is not boolean", "k #when=param:p", "when tag value 'param:p' is not a boolean parameter for key 'k'"},
+	} {
+		t.Run(data.name, func(t *testing.T) {
+			b := NewContainerBuilder()
+			b.SetValue("p", "abc")
+			b.SetFactory("f", dummyFactory)
+			b.SetFactory(data.key, dummyFactory)
+
+			assert.PanicsWithValue(t, data.error, func() {
+				b.GetContainer()
+			})
+		})
+	}
+
+	t.Run("panics if circular conditions", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetFactory("a #if-missing=b", dummyFactory)
+		b.SetFactory("b #if-missing=a", dummyFactory)
+
+		assert.PanicsWithValue(t, "circular conditions found between definitions [a b]", func() {
+			b.GetContainer()
+		})
+	})
+}
+
+func TestCondition_String(t *testing.T) {
+	c := Condition{Key: "k", Tag: TagIfPresent, Value: "other", Passed: false}
+	assert.Equal(t, "k #if-present=other failed", c.String())
+}
@@ -5,9 +5,11 @@
 package di
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 )
 
 // Container is the public container interface to retrieve built instances of services, though it is used mainly on
@@ -17,89 +19,248 @@ type Container interface {
 	GetTaggedBy(tag string, values ...string) []interface{}
 }
 
+// Lifecycle groups the methods of a resolved container managing the construction and the built instances of its
+// services, see Check, Warmup and Reset.
+type Lifecycle interface {
+	Check() error
+	MustBuild(dry bool)
+	Warmup(opts WarmupOptions) *WarmupReport
+	WarmupParallel(ctx context.Context, workers int) error
+	Reset(keys ...string)
+	Snapshot() *Snapshot
+	Restore(s *Snapshot)
+}
+
+// ResolvedContainer is the container returned by ContainerBuilder.GetContainer, which besides retrieving services can
+// inspect and manage them.
+type ResolvedContainer interface {
+	Container
+	Lifecycle
+	Provide(key string, value interface{})
+	Query(query string) ([]interface{}, error)
+	QueryKeys(query string) ([]string, error)
+	Graph() *Graph
+	AddObserver(os ...Observer)
+	Override(key string, value interface{}) (restore func())
+}
+
 // container is the result of resolving a containerBuilder instance. It can build and return any service previously
 // defined in the mentioned containerBuilder.
 type container struct {
-	builder   *containerBuilder
-	instances map[string]interface{}
-	sealed    bool
-	loading   []string
-	lock      *sync.Mutex
+	builder      *containerBuilder
+	root         *container
+	instances    map[string]interface{}
+	sealed       bool
+	loading      []string
+	synthetics   *sync.Map
+	deprecations *deprecations
+	edges        *observedEdges
+	observers    *observers
+	scoped       []Observer
+	singletons   *singletons
+	tags         *tagIndex
+	overrides    *overrides
+	lock         *sync.Mutex
 }
 
-// Get will retrieve a service form the container by a given key. It will panic if service is not found or if the
-// requested service has been configured as private.
+// singletons guards the instances of shared services and their construction, so different shared services can be
+// built concurrently while each one is only built once. It is shared between a container and its unsealed copies.
+type singletons struct {
+	keys       map[string]*sync.Mutex
+	tagged     map[string][]interface{}
+	generation uint64
+	lock       sync.Mutex
+}
+
+// keyLock returns the lock used to build the shared service on the given key.
+func (s *singletons) keyLock(key string) *sync.Mutex {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	l, ok := s.keys[key]
+	if !ok {
+		l = &sync.Mutex{}
+		s.keys[key] = l
+	}
+
+	return l
+}
+
+// Get will retrieve a service form the container by a given key. It will panic if service is not found, if the
+// requested service has been configured as private or if it is abstract.
 func (c *container) Get(key string) interface{} {
-	def := c.builder.GetDefinition(key)
+	def := c.definition(key)
+	if def == nil {
+		panic(fmt.Sprintf("service with key '%s' not found", key))
+	}
+
+	if def.Abstract {
+		panic(fmt.Sprintf("service with key '%s' is abstract and can't be retrieved from the container", key))
+	}
+
 	if c.sealed && def.Private {
 		panic(fmt.Sprintf("service with key '%s' is private and can't be retrieved from the container", key))
 	}
 
+	if def.HasTag(TagDeprecated) {
+		c.deprecations.notify(def, key, c.loading)
+	}
+
+	c.edges.observe(c.loading, key)
+
 	if !def.Shared {
 		return c.construct(def, key)
 	}
 
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	c.panicIfCircular(key)
+
+	l := c.singletons.keyLock(key)
+	l.Lock()
+	defer l.Unlock()
 
-	if i, ok := c.instances[key]; ok {
+	c.singletons.lock.Lock()
+	i, ok := c.instances[key]
+	c.singletons.lock.Unlock()
+
+	if ok {
+		for _, o := range c.notified() {
+			o.OnCacheHit(key)
+		}
 		return reflect.ValueOf(i).Elem().Interface()
 	}
 
 	s := c.construct(def, key)
 
+	c.singletons.lock.Lock()
 	c.instances[key] = &s
+	c.singletons.lock.Unlock()
 
 	return s
 }
 
 // GetTaggedBy returns all services related to a given tag. If values provided, then only the services which match
 // with tag and value will be returned. Services are sorted by priority defined with the #priotity tag. If not defined,
-// priority is zero. Services with higher priority are returned first.
+// priority is zero. Services with higher priority are returned first. Keys are looked up in the tag index built when
+// the container was resolved, and lists made only of public shared services are cached until instances are reset.
 func (c *container) GetTaggedBy(tag string, values ...string) []interface{} {
-	keys := c.builder.GetTaggedKeys(tag, values)
+	keys := c.tags.keys(tag, values)
+
+	cacheable := c.cacheable(keys)
+	var cacheKey string
+	var generation uint64
+	if cacheable {
+		var cached []interface{}
+		var ok bool
+		cacheKey = taggedCacheKey(tag, values)
+		if cached, ok, generation = c.singletons.cachedTagged(cacheKey); ok {
+			return append(make([]interface{}, 0, len(cached)), cached...)
+		}
+	}
+
 	defs := make([]interface{}, 0, len(keys))
 	for _, key := range keys {
 		defs = append(defs, c.Get(key))
 	}
 
+	if cacheable {
+		c.singletons.cacheTagged(cacheKey, append(make([]interface{}, 0, len(defs)), defs...), generation)
+	}
+
 	return defs
 }
 
-// MustBuild builds all the public services at once to discover unexpected panics on runtime. If given false as parameter,
-// singleton services instances will be preserved. On the contrary, a "dry" build will be executed and all built services
-// will be removed to have a fresh container.
-func (c *container) MustBuild(dry bool) {
-	for k, d := range c.builder.definitions {
-		if d.Private {
-			continue
-		}
-		_ = c.Get(k)
+// Provide sets the value of a synthetic service on current container. It panics if the definition on the given key is
+// not synthetic or if the value is not assignable to the declared type of the synthetic service.
+func (c *container) Provide(key string, value interface{}) {
+	def, ok := c.builder.definitions[key]
+	if !ok || def.Kind != TagSynthetic {
+		panic(fmt.Sprintf("service with key '%s' is not synthetic and can't be provided", key))
 	}
 
-	if dry {
-		c.instances = make(map[string]interface{})
+	if def.Type != nil && (value == nil || !reflect.TypeOf(value).AssignableTo(def.Type)) {
+		msg := "value of type %T can't be provided for synthetic service with key '%s' of type %s"
+		panic(fmt.Sprintf(msg, value, key, def.Type))
 	}
+
+	c.synthetics.Store(key, value)
+}
+
+// getSynthetic returns the value provided for a synthetic service or panics if it has not been provided yet.
+func (c *container) getSynthetic(key string) interface{} {
+	v, ok := c.synthetics.Load(key)
+	if !ok {
+		panic(fmt.Sprintf("synthetic service with key '%s' has not been provided to the container", key))
+	}
+
+	return v
 }
 
 // construct builds the service from the given definition. It detects circular referenced dependencies by checking if
-// the key has already been built in current dependencies graph.
-func (c *container) construct(def *definition, key string) interface{} {
+// the key has already been built in current dependencies graph, which is guarded by the container lock as locators
+// read it from other goroutines. Observers are notified before and after the build,
+// including the panic as error, if any, even if other observers are added meanwhile.
+func (c *container) construct(def *definition, key string) (s interface{}) {
+	c.panicIfCircular(key)
+
+	u := c.unseal()
+	u.lock.Lock()
+	u.loading = append(u.loading, key)
+	u.lock.Unlock()
+
+	observers := c.notified()
+	for _, o := range observers {
+		o.OnBeforeBuild(key, append([]string(nil), u.loading...))
+	}
+
+	start := time.Now()
+	defer func() {
+		u.lock.Lock()
+		u.loading = u.loading[:len(u.loading)-1]
+		u.lock.Unlock()
+		if len(observers) == 0 {
+			return
+		}
+
+		r := recover()
+		var err error
+		if r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+
+		d := time.Since(start)
+		for _, o := range observers {
+			o.OnAfterBuild(key, s, d, err)
+		}
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	val := reflect.ValueOf(def.Factory).Call([]reflect.Value{reflect.ValueOf(u)})
+
+	return val[0].Interface()
+}
+
+// panicIfCircular panics if the service on the given key is already being built in current dependencies graph.
+func (c *container) panicIfCircular(key string) {
 	for i := 0; i < len(c.loading); i++ {
 		if c.loading[i] == key {
 			msg := "circular reference found while building service '%s' at service '%s'"
 			panic(fmt.Sprintf(msg, c.loading[0], c.loading[len(c.loading)-1]))
 		}
 	}
+}
 
-	u := c.unseal()
-	u.loading = append(u.loading, key)
-
-	val := reflect.ValueOf(def.Factory).Call([]reflect.Value{reflect.ValueOf(u)})
-
-	u.loading = u.loading[:len(u.loading)-1]
+// view returns a new unsealed copy of the root container with a copy of the given dependencies graph, so services can
+// be built out of a factory call, like from a stored locator, without sharing the graph with other goroutines.
+func (c *container) view(loading []string) *container {
+	v := *c.root
+	v.lock = &sync.Mutex{}
+	v.loading = append(make([]string, 0, len(loading)+10), loading...)
+	v.sealed = false
 
-	return val[0].Interface()
+	return &v
 }
 
 // unseal returns an unsealed version of current container to allow private services to be injected in other services.
@@ -109,6 +270,7 @@ func (c *container) unseal() *container {
 	}
 	uc := *c
 	uc.lock = &sync.Mutex{}
+	uc.loading = append(make([]string, 0, cap(c.loading)), c.loading...)
 	uc.sealed = false
 
 	return &uc
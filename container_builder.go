@@ -7,26 +7,35 @@ package di
 import (
 	"fmt"
 	"reflect"
-	"sort"
+	"runtime"
+	"strings"
 	"sync"
 )
 
 // This is the list of reserved tags with relevant meaning for the container.
 const (
-	TagShared   = "shared"
-	TagPrivate  = "private"
-	TagPriority = "priority"
-	TagInject   = "inject"
-	TagValue    = "value"
-	TagAlias    = "alias"
-	TagFactory  = "factory"
+	TagShared    = "shared"
+	TagPrivate   = "private"
+	TagPriority  = "priority"
+	TagInject    = "inject"
+	TagValue     = "value"
+	TagAlias     = "alias"
+	TagFactory   = "factory"
+	TagAbstract  = "abstract"
+	TagSynthetic = "synthetic"
+	TagOverride  = "override"
 )
 
+// injectOverridePrefix is the prefix of the tags used on child definitions to override the key injected into a field
+// of an injectable parent, e.g. "inject.Mailer" = "mailer.smtp".
+const injectOverridePrefix = TagInject + "."
+
 // Binding represents the information required to declare or bind a service definition into the container.
 type Binding struct {
-	Key    string
-	Target interface{}
-	Tags   map[string]string
+	Key       string
+	Target    interface{}
+	Tags      map[string]string
+	TagValues map[string][]string
 }
 
 // ContainerBuilder interface declares the public API for containerBuilder type. ContainerBuilder is used
@@ -40,11 +49,19 @@ type Binding struct {
 //	  is required to use pointers for returned services to work as real singletons.
 // 	- TagPrivate: default tag value "true", declares a service as "private" and it will be available to be injected as
 //	  dependency of another service but not available to be retrieved from current container.
-//	- TagValue, TagFactory, TagInject and TagAlias: default tag value "", these tags are used with bindings and SetAll
-//	  method to indicate the container which kind of service to use (a value, a factory, an injectable struct or an alias).
+//	- TagValue, TagFactory, TagInject, TagAlias and TagSynthetic: default tag value "", these tags are used with bindings
+//	  and SetAll method to indicate the container which kind of service to use (a value, a factory, an injectable struct,
+//	  an alias or a synthetic service).
 //	- TagPriority: default tag value "0", can be used to sort the services by priority when retrieving services by tag.
 //    The higher the value, the higher the priority. Services will be sorted and the ones with higher priority will be
 //    returned on the lowest indexes of the result slice.
+//	- TagWhen, TagIfMissing and TagIfPresent: declare conditional services which are removed from the container when
+//	  resolved if the condition is not met, restoring the definition they overwrote if any. See evaluateCondition for
+//	  the supported values.
+//	- TagDeprecated: default tag value "", declares a service as deprecated and the tag value is used as migration hint.
+//	  Using it from the container will warn through the builder's DeprecationLogger, once per key and container.
+//	- TagAbstract: default tag value "true", declares a service as "abstract", a template which can't be retrieved from
+//	  the container nor by tag, but can be extended by child definitions using SetChild.
 //
 // Additionally, tags can also be indicated in the key of the service. Use the "#" char to indicate a tag. Tag values can
 // also be indicated by this method using the "=" followed by the value of the tag. Key portion, tags and values will be
@@ -54,39 +71,71 @@ type Binding struct {
 // 	- Example: "my.key #private # custom = abc" -> { Key: "my.key", Tags: {"private": "", "custom": "abc" }
 //
 // By default, services can be overwritten by using the same key as an existing one. Aliases can also be overwritten but
-// trying to set an alias with a key used by a real service definition will fail.
+// trying to set an alias with a key used by a real service definition will fail. In strict mode, overwriting a service
+// registered by a different provider requires the TagOverride tag.
 type ContainerBuilder interface {
 	SetAll(all ...Binding)
-	SetValue(key string, value interface{}, tags ...map[string]string) *definition
-	SetFactory(key string, factory func(Container) interface{}, tags ...map[string]string) *definition
-	SetInjectable(key string, value interface{}, tags ...map[string]string) *definition
-	SetAlias(key, def string, tags ...map[string]string) *definition
+	SetValue(key string, value interface{}, tags ...map[string]string) DefinitionBuilder
+	SetFactory(key string, factory func(Container) interface{}, tags ...map[string]string) DefinitionBuilder
+	SetInjectable(key string, value interface{}, tags ...map[string]string) DefinitionBuilder
+	SetAlias(key, def string, tags ...map[string]string) DefinitionBuilder
+	SetChild(key, parent string, overrides ...map[string]string) DefinitionBuilder
+	SetSynthetic(key string, typ reflect.Type, tags ...map[string]string) DefinitionBuilder
+	SetLocator(key string, entries ...string) DefinitionBuilder
 	HasDefinition(key string) bool
-	GetDefinition(key string) *definition
+	GetDefinition(key string) Definition
+	GetHistory(key string) []Definition
 	GetTaggedKeys(tag string, values []string) []string
-	GetContainer() *container
+	QueryKeys(query string) ([]string, error)
+	GetContainer() ResolvedContainer
+	AddProvider(ps ...Provider)
+	AddResolver(rs ...Resolver)
+	SetStrict(strict bool)
+	SetTagComparator(cmp TagComparator)
+	SetDeprecationLogger(logger DeprecationLogger)
+	RegisterTag(schemas ...TagSchema)
+	Conditions() []Condition
+	DeprecationReport() []Deprecation
 }
 
 // containerBuilder implements ContainerBuilder interface to bind service definitions
 // and resolve the final service container.
 type containerBuilder struct {
-	definitions map[string]*definition
-	providers   []Provider
-	resolvers   []Resolver
-	resolved    bool
-	reentrant   bool
-	lock        *sync.Mutex
+	definitions       map[string]*definition
+	order             map[string]int
+	tagComparator     TagComparator
+	schemas           *tagSchemas
+	index             *tagIndex
+	providers         []Provider
+	resolvers         []Resolver
+	conditions        []Condition
+	deprecationLogger DeprecationLogger
+	registrar         string
+	origin            int
+	strict            bool
+	resolved          bool
+	reentrant         bool
+	lock              *sync.Mutex
+}
+
+// NewContainerBuilder returns a new ContainerBuilder instance.
+func NewContainerBuilder() ContainerBuilder {
+	return newContainerBuilder()
 }
 
-// NewContainerBuilder returns a pointer to a new containerBuilder instance.
-func NewContainerBuilder() *containerBuilder {
+// newContainerBuilder returns a pointer to a new containerBuilder instance.
+func newContainerBuilder() *containerBuilder {
 	return &containerBuilder{
-		definitions: make(map[string]*definition),
-		providers:   make([]Provider, 0),
-		resolvers:   make([]Resolver, 0),
-		resolved:    false,
-		reentrant:   false,
-		lock:        &sync.Mutex{},
+		definitions:       make(map[string]*definition),
+		order:             make(map[string]int),
+		schemas:           &tagSchemas{schemas: make(map[string]TagSchema)},
+		providers:         make([]Provider, 0),
+		resolvers:         make([]Resolver, 0),
+		conditions:        make([]Condition, 0),
+		deprecationLogger: defaultDeprecationLogger,
+		resolved:          false,
+		reentrant:         false,
+		lock:              &sync.Mutex{},
 	}
 }
 
@@ -102,36 +151,126 @@ func (c *containerBuilder) panicIfResolved() {
 }
 
 // setDefinition binds a service factory into the containerBuilder on a specific key and an optional list of tags. Tags
-// can also be indicated in the key.
+// can also be indicated in the key. It records the registering provider and the source location of the definition, and
+// keeps the overwritten definition, if any. In strict mode, it panics if a definition registered by another provider
+// would be overwritten without the TagOverride tag.
 func (c *containerBuilder) setDefinition(key string, factory func(c Container) interface{}, tags ...map[string]string) *definition {
 	c.panicIfResolved()
 
-	k, t := parseKey(key)
+	k, values, err := parseKeyValues(key)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	def, err := newDefinition(c.schemas, factory, append(tags, firstTagValues(values))...)
+	if err != nil {
+		panic(fmt.Sprintf("%s for key '%s'", err, k))
+	}
+	setTagValues(def, k, values, tags...)
 
-	tags = append(tags, t)
-	def, err := newDefinition(factory, tags...)
+	override, err := parseBoolTag(TagOverride, def.Tags)
 	if err != nil {
 		panic(fmt.Sprintf("%s for key '%s'", err, k))
 	}
+
+	def.key = k
+	def.Provider = c.registrar
+	def.Source = callerLocation()
+	def.origin = c.origin
+
+	if old, ok := c.definitions[k]; ok {
+		if c.strict && !override && old.AliasOf == nil && old.origin != def.origin {
+			msg := "definition with id '%s' registered at %s can't be overwritten from %s without the %s tag"
+			panic(fmt.Sprintf(msg, k, old.Source, def.Source, TagOverride))
+		}
+		def.Overwrites = old
+	}
+	if _, ok := c.order[k]; !ok {
+		c.order[k] = len(c.order)
+	}
 	c.definitions[k] = def
 
 	return def
 }
 
+// setTagValues sets on the given definition all the values of the given multi-valued tags, except the ones set by any
+// of the given tag maps, which take precedence. It panics if some tag value is not valid.
+func setTagValues(def *definition, key string, values map[string][]string, precedent ...map[string]string) {
+	for tag, vs := range values {
+		if len(vs) < 2 || inTagMaps(tag, precedent) {
+			continue
+		}
+
+		if err := def.setTagValues(tag, vs); err != nil {
+			panic(fmt.Sprintf("%s for key '%s'", err, key))
+		}
+	}
+}
+
+// inTagMaps returns if the given tag is in any of the given tag maps.
+func inTagMaps(tag string, tagsList []map[string]string) bool {
+	for _, tags := range tagsList {
+		if _, ok := tags[tag]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// builderMethodPrefixes are the prefixes of the function names of the containerBuilder and definitionBuilder methods in
+// stack traces.
+var builderMethodPrefixes = []string{
+	reflect.TypeOf(containerBuilder{}).PkgPath() + ".(*containerBuilder).",
+	reflect.TypeOf(definitionBuilder{}).PkgPath() + ".(*definitionBuilder).",
+}
+
+// isBuilderMethod returns if the given function name is the one of a containerBuilder or definitionBuilder method.
+func isBuilderMethod(function string) bool {
+	for _, p := range builderMethodPrefixes {
+		if strings.HasPrefix(function, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// callerLocation returns the file and line of the first caller outside the containerBuilder and definitionBuilder
+// methods, which is the place where a definition was declared.
+func callerLocation() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		f, more := frames.Next()
+		if !isBuilderMethod(f.Function) {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// SetStrict enables or disables the strict mode. In strict mode, overwriting a non-alias definition registered by a
+// different provider or resolver, or directly on the builder, panics unless the new definition has the TagOverride tag.
+func (c *containerBuilder) SetStrict(strict bool) {
+	c.panicIfResolved()
+	c.strict = strict
+}
+
 // SetValue adds a new value or instance definition to the container on a given Key. When retrieving from the container
 // by the given key, it will always return the given value.
-func (c *containerBuilder) SetValue(key string, value interface{}, tags ...map[string]string) *definition {
+func (c *containerBuilder) SetValue(key string, value interface{}, tags ...map[string]string) DefinitionBuilder {
 	tags = append(tags, map[string]string{TagValue: ""})
-	return c.setDefinition(key, func(_ Container) interface{} {
+	return c.configure(c.setDefinition(key, func(_ Container) interface{} {
 		return value
-	}, tags...)
+	}, tags...))
 }
 
 // SetFactory adds a new factory definition to the container referenced by a given Key. When retrieving from the container
 // by the given key, the container will call this factory to create the corresponding service.
-func (c *containerBuilder) SetFactory(key string, factory func(Container) interface{}, tags ...map[string]string) *definition {
+func (c *containerBuilder) SetFactory(key string, factory func(Container) interface{}, tags ...map[string]string) DefinitionBuilder {
 	tags = append(tags, map[string]string{TagFactory: ""})
-	return c.setDefinition(key, factory, tags...)
+	return c.configure(c.setDefinition(key, factory, tags...))
 }
 
 // SetInjectable adds a new injectable struct definition to the container on a given key. Given struct must contain at
@@ -143,9 +282,10 @@ func (c *containerBuilder) SetFactory(key string, factory func(Container) interf
 //
 // As shown in the example above, with the "inject" label we can configure the dependencies of the injectable service by
 // indicating the key of the required dependency. When retrieving this service by the given key, the container will
-// inject the indicated dependencies. Unexported members are not supported to be injected because trying to do so would
-// produce a panic setting field's value with reflection.
-func (c *containerBuilder) SetInjectable(key string, i interface{}, tags ...map[string]string) *definition {
+// inject the indicated dependencies. Fields of type Container can also receive a restricted locator instead of a
+// service by using the "locator:" prefix, see SetLocator. Unexported members are not supported to be injected because
+// trying to do so would produce a panic setting field's value with reflection.
+func (c *containerBuilder) SetInjectable(key string, i interface{}, tags ...map[string]string) DefinitionBuilder {
 	t := reflect.TypeOf(i)
 	isPtr := false
 	if t.Kind() == reflect.Ptr {
@@ -173,34 +313,26 @@ func (c *containerBuilder) SetInjectable(key string, i interface{}, tags ...map[
 			panic(fmt.Sprintf("no injection key present for field %s: %s", t.Name(), f.Name))
 		}
 
-		fields[j] = k
-	}
-
-	tags = append(tags, map[string]string{TagInject: ""})
-	d := c.setDefinition(key, func(c Container) interface{} {
-		t := reflect.New(t)
-		e := t.Elem()
-		for i, k := range fields {
-			p := c.Get(k)
-			v := reflect.ValueOf(p)
-			e.Field(i).Set(v)
+		if strings.HasPrefix(k, locatorPrefix) && !reflect.TypeOf(&locator{}).AssignableTo(f.Type) {
+			panic(fmt.Sprintf("locator can not be injected in field %s: %s of type %s", t.Name(), f.Name, f.Type))
 		}
 
-		if isPtr {
-			return t.Interface()
-		}
+		fields[j] = k
+	}
 
-		return e.Interface()
-	}, tags...)
+	inj := &injection{Type: t, IsPtr: isPtr, Fields: fields}
 
-	return d
+	tags = append(tags, map[string]string{TagInject: ""})
+	d := c.setDefinition(key, inj.Factory(), tags...)
+	d.Injection = inj
 
+	return c.configure(d)
 }
 
 // SetAlias sets an alias for an existing definition on a given key. Aliases inherit the aliased service factory, but
 // they can have their own set of tags. As an example, a service might be "private" and the corresponding alias can be
 // public or even a singleton. Aliases can be replaced by real services definitions, the contrary will fail.
-func (c *containerBuilder) SetAlias(key, def string, tags ...map[string]string) *definition {
+func (c *containerBuilder) SetAlias(key, def string, tags ...map[string]string) DefinitionBuilder {
 
 	if d, ok := c.definitions[key]; ok && d.AliasOf == nil {
 		panic(fmt.Sprintf("definition with id '%s' already exists and alias cannot be set", key))
@@ -215,13 +347,107 @@ func (c *containerBuilder) SetAlias(key, def string, tags ...map[string]string)
 	d := c.setDefinition(key, aliased.Factory, tags...)
 	d.AliasOf = aliased
 
-	return d
+	return c.configure(d)
+}
+
+// SetChild adds a new definition on a given key which extends an existing parent definition, usually an abstract one.
+// Children inherit the parent's factory, kind, alias target and tags, except TagAbstract and TagDeprecated. Override
+// tags, either given as arguments or in the key, have precedence over the inherited ones. Children of injectable
+// definitions can also replace the key injected into a field by using the "inject." prefix followed by the field name:
+//
+//	b.SetInjectable("handler.base #abstract #private #priority=5", Handler{})
+//	b.SetChild("handler.users #inject.Repo=repo.users", "handler.base", map[string]string{TagPrivate: "false"})
+//
+// Parent definitions are copied at the moment of the call, so later changes on the parent won't affect the child.
+func (c *containerBuilder) SetChild(key, parent string, overrides ...map[string]string) DefinitionBuilder {
+	p, ok := c.definitions[parent]
+	if !ok {
+		panic(fmt.Sprintf("definition with id '%s' does not exist and child cannot be set", parent))
+	}
+
+	k, values, err := parseKeyValues(key)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	own := make(map[string]string)
+	fields := make(map[string]string)
+	for tagName, tagValue := range mergeTags(append(overrides, firstTagValues(values))...) {
+		if strings.HasPrefix(tagName, injectOverridePrefix) {
+			fields[strings.TrimPrefix(tagName, injectOverridePrefix)] = tagValue
+			continue
+		}
+		own[tagName] = tagValue
+	}
+
+	inherited := make(map[string]string, len(p.Tags))
+	for tagName, tagValue := range p.Tags {
+		if tagName != TagAbstract && tagName != TagDeprecated {
+			inherited[tagName] = tagValue
+		}
+	}
+
+	factory := p.Factory
+	inj := p.Injection
+	if len(fields) > 0 {
+		if inj == nil {
+			panic(fmt.Sprintf("definition with id '%s' is not injectable and child fields cannot be overridden", parent))
+		}
+
+		var err error
+		if inj, err = inj.override(fields); err != nil {
+			panic(fmt.Sprintf("%s for key '%s'", err, k))
+		}
+		factory = inj.Factory()
+	}
+
+	d := c.setDefinition(k, factory, own, inherited)
+	for tagName := range inherited {
+		if _, ok := own[tagName]; !ok {
+			d.copyTag(p, tagName)
+		}
+	}
+	for tagName := range values {
+		if strings.HasPrefix(tagName, injectOverridePrefix) {
+			delete(values, tagName)
+		}
+	}
+	setTagValues(d, k, values, overrides...)
+	d.AliasOf = p.AliasOf
+	d.Parent = p
+	d.Injection = inj
+
+	return c.configure(d)
 }
 
-// SetAll adds given bindings into the containerBuilder. Reserved tags TagValue, TagAlias, TagFactory and TagInject;
-// are used to determine the kind of service definition to consider for each Binding. By default, TagFactory is used
-// if no other kind is indicated. Commented tags are all mutually exclusive and adding more than one per Binding will
-// fail.
+// SetSynthetic adds a placeholder definition on a given key for a service which is only known once the container is
+// built, like the current request or the command line arguments. Other services can depend on it as on any other
+// service, but the actual value must be given to each container with its Provide method before being requested. The
+// given type, if not nil, is used to validate the provided values:
+//
+//	b.SetSynthetic("request", reflect.TypeOf((*http.Request)(nil)))
+//	...
+//	c := b.GetContainer()
+//	c.Provide("request", r)
+func (c *containerBuilder) SetSynthetic(key string, typ reflect.Type, tags ...map[string]string) DefinitionBuilder {
+	k, _, err := parseKey(key)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	tags = append(tags, map[string]string{TagSynthetic: ""})
+	d := c.setDefinition(key, func(c Container) interface{} {
+		return c.(*container).getSynthetic(k)
+	}, tags...)
+	d.Type = typ
+
+	return c.configure(d)
+}
+
+// SetAll adds given bindings into the containerBuilder. Reserved tags TagValue, TagAlias, TagFactory, TagInject and
+// TagSynthetic are used to determine the kind of service definition to consider for each Binding. By default,
+// TagFactory is used if no other kind is indicated. Commented tags are all mutually exclusive and adding more than one
+// per Binding will fail.
 //
 //	b.SetAll([]Binding{
 //		{Key: "key1 #factory", Target: func(c Container) interface{} {
@@ -230,6 +456,7 @@ func (c *containerBuilder) SetAlias(key, def string, tags ...map[string]string)
 //		{Key: "key2", Target: 2, Tags: map[string]string{TagValue: ""}},
 //		{Key: "key3", Target: "key2", Tags: map[string]string{TagAlias: ""}},
 //		{Key: "key4", Target: struct{}{}, Tags: map[string]string{TagInject: ""}},
+//		{Key: "key6", Target: reflect.TypeOf(""), Tags: map[string]string{TagSynthetic: ""}},
 //		{Key: "key5", Target: func(c Container) interface{} {  	// <- defaults to TagFactory
 //			return 5
 //		}},
@@ -237,8 +464,12 @@ func (c *containerBuilder) SetAlias(key, def string, tags ...map[string]string)
 //	}...)
 func (c *containerBuilder) SetAll(all ...Binding) {
 	for _, b := range all {
-		k, parsedTags := parseKey(b.Key)
-		mergedTags := mergeTags(b.Tags, parsedTags)
+		k, parsedValues, err := parseKeyValues(b.Key)
+		if err != nil {
+			panic(err.Error())
+		}
+		bindingTags := firstTagValues(b.TagValues)
+		mergedTags := mergeTags(b.Tags, bindingTags, firstTagValues(parsedValues))
 
 		kind, err := selectKindTag(mergedTags)
 		if err != nil {
@@ -252,11 +483,18 @@ func (c *containerBuilder) SetAll(all ...Binding) {
 			c.SetValue(k, b.Target, mergedTags)
 		case TagInject:
 			c.SetInjectable(k, b.Target, mergedTags)
+		case TagSynthetic:
+			typ, _ := b.Target.(reflect.Type)
+			c.SetSynthetic(k, typ, mergedTags)
 		case TagFactory:
 			fallthrough
 		default:
 			c.SetFactory(k, b.Target.(func(Container) interface{}), mergedTags)
 		}
+
+		d := c.definitions[k]
+		setTagValues(d, k, b.TagValues, b.Tags)
+		setTagValues(d, k, parsedValues, b.Tags, bindingTags)
 	}
 }
 
@@ -266,43 +504,52 @@ func (c *containerBuilder) HasDefinition(key string) bool {
 	return ok
 }
 
+// GetHistory returns the definitions overwritten by the current one on the given key, the most recent first.
+func (c *containerBuilder) GetHistory(key string) []Definition {
+	history := make([]Definition, 0)
+	if def, ok := c.definitions[key]; ok {
+		for d := def.Overwrites; d != nil; d = d.Overwrites {
+			history = append(history, d)
+		}
+	}
+
+	return history
+}
+
 // GetDefinition retrieves a container definition for the given key or nil if not found.
-func (c *containerBuilder) GetDefinition(key string) *definition {
-	def := c.definitions[key]
-	return def
+func (c *containerBuilder) GetDefinition(key string) Definition {
+	if def, ok := c.definitions[key]; ok {
+		return def
+	}
+
+	return nil
 }
 
 // GetTaggedKeys returns all keys related to a given tag. If values provided, then only the keys which match with tag and
-// value will be returned. The resulting list will be sorted by definition's priority.
+// any of the values will be returned. The resulting list will be sorted by definition's priority, or by the priority
+// attribute of the matching tag values if any, see SetTagComparator.
 func (c *containerBuilder) GetTaggedKeys(tag string, values []string) []string {
-	tagged := make([]Binding, 0)
+	keys := make([]string, 0)
 	for key, def := range c.definitions {
-		tagVal, ok := def.Tags[tag]
-		if !ok {
+		if !def.HasTag(tag) || def.Abstract {
 			continue
 		}
 
 		if len(values) == 0 {
-			tagged = append(tagged, Binding{Key: key, Target: def})
+			keys = append(keys, key)
 			continue
 		}
 
 		for _, v := range values {
-			if v == tagVal {
-				tagged = append(tagged, Binding{Key: key, Target: def})
+			if def.HasTagValue(tag, v) {
+				keys = append(keys, key)
 				break
 			}
 		}
 	}
 
-	sort.SliceStable(tagged, func(i, j int) bool {
-		return tagged[i].Target.(*definition).Priority > tagged[j].Target.(*definition).Priority
-	})
+	c.sortTagged(keys, tag, values)
 
-	keys := make([]string, 0, len(tagged))
-	for _, i := range tagged {
-		keys = append(keys, i.Key)
-	}
 	return keys
 }
 
@@ -343,8 +590,14 @@ func (c *containerBuilder) AddResolver(rs ...Resolver) {
 	}
 }
 
-// GetContainer resolves and returns the container instance declared on current containerBuilder.
-func (c *containerBuilder) GetContainer() *container {
+// GetContainer resolves and returns the container instance declared on current containerBuilder. Conditional
+// definitions are evaluated once all providers have been run, and again for the ones added by resolvers.
+func (c *containerBuilder) GetContainer() ResolvedContainer {
+	return c.resolve()
+}
+
+// resolve resolves the builder the first time it is called and returns a new container of its definitions.
+func (c *containerBuilder) resolve() *container {
 	if c.reentrant {
 		panic("get container reentrant call error")
 	}
@@ -356,22 +609,44 @@ func (c *containerBuilder) GetContainer() *container {
 		rc := *c
 		rc.reentrant = true
 		rc.lock = &sync.Mutex{}
-		for _, p := range c.providers {
+		for i, p := range c.providers {
+			rc.registrar, rc.origin = fmt.Sprintf("%T", p), i+1
 			p.Provide(&rc)
 		}
 
-		for _, r := range c.resolvers {
+		c.evaluateConditions()
+
+		for i, r := range c.resolvers {
+			rc.registrar, rc.origin = fmt.Sprintf("%T", r), -(i + 1)
 			r.Resolve(&rc)
 		}
 
+		c.evaluateConditions()
+
+		c.index = c.buildTagIndex()
 		c.resolved = true
+
+		// the copy is also resolved, so definitions set by providers and resolvers can't be configured afterwards
+		rc.lock.Lock()
+		rc.resolved = true
+		rc.lock.Unlock()
 	}
 
-	return &container{
-		builder:   c,
-		instances: make(map[string]interface{}),
-		sealed:    true,
-		loading:   make([]string, 0, 10),
-		lock:      &sync.Mutex{},
+	rc := &container{
+		builder:      c,
+		instances:    make(map[string]interface{}),
+		sealed:       true,
+		loading:      make([]string, 0, 10),
+		synthetics:   &sync.Map{},
+		deprecations: &deprecations{logger: c.deprecationLogger, notified: make(map[string]bool)},
+		edges:        &observedEdges{edges: make(map[GraphEdge]bool)},
+		singletons:   &singletons{keys: make(map[string]*sync.Mutex), tagged: make(map[string][]interface{})},
+		tags:         c.index,
+		overrides:    &overrides{defs: make(map[string]*definition)},
+		observers:    &observers{},
+		lock:         &sync.Mutex{},
 	}
+	rc.root = rc
+
+	return rc
 }
@@ -7,6 +7,8 @@ package di
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -15,7 +17,8 @@ var dummyResolver = ResolverFunc(func(ContainerBuilder) {})
 
 func TestNewContainerBuilder(t *testing.T) {
 	t.Run("returns an initialized builder", func(t *testing.T) {
-		b := NewContainerBuilder()
+		b, ok := NewContainerBuilder().(*containerBuilder)
+		assert.True(t, ok)
 		assert.NotNil(t, b.definitions)
 		assert.NotNil(t, b.providers)
 		assert.Len(t, b.definitions, 0)
@@ -32,7 +35,7 @@ func testSetMethodsCommon(t *testing.T, kindTag string, f func(b ContainerBuilde
 		f(b, "k1")
 		assert.True(t, b.HasDefinition("k1"))
 		assert.True(t, b.GetDefinition("k1").HasTag(kindTag))
-		assert.Equal(t, 1, len(b.GetDefinition("k1").Tags))
+		assert.Equal(t, 1, len(b.GetDefinition("k1").GetTags()))
 	})
 
 	t.Run("adds definition with tags", func(t *testing.T) {
@@ -87,16 +90,16 @@ func testSetMethodsReplaceAlias(t *testing.T, f func(b ContainerBuilder, key str
 		b.SetAlias(alias, key)
 		a := b.GetDefinition(alias)
 		k := b.GetDefinition(key)
-		assert.EqualValues(t, a.Factory(c), k.Factory(c))
-		assert.Equal(t, k, a.AliasOf)
+		assert.EqualValues(t, a.GetFactory()(c), k.GetFactory()(c))
+		assert.Equal(t, k, a.GetAliasOf())
 
 		f(b, alias)
 		assert.True(t, b.HasDefinition(alias))
 
 		a = b.GetDefinition(alias)
 		k = b.GetDefinition(key)
-		assert.NotEqualValues(t, a.Factory(c), k.Factory(c))
-		assert.Nil(t, a.AliasOf)
+		assert.NotEqualValues(t, a.GetFactory()(c), k.GetFactory()(c))
+		assert.Nil(t, a.GetAliasOf())
 	})
 }
 
@@ -134,7 +137,7 @@ func TestContainerBuilder_SetValue(t *testing.T) {
 				c := b.GetContainer()
 
 				assert.True(t, b.HasDefinition(key))
-				assert.Equal(t, val, b.GetDefinition(key).Factory(c))
+				assert.Equal(t, val, b.GetDefinition(key).GetFactory()(c))
 			})
 		}
 	})
@@ -275,6 +278,93 @@ func TestContainerBuilder_SetAlias(t *testing.T) {
 	})
 }
 
+func TestContainerBuilder_SetChild(t *testing.T) {
+	testSetMethodsReplaceAlias(t, func(b ContainerBuilder, key string) {
+		b.SetFactory("parent", dummyFactory)
+		b.SetChild(key, "parent")
+	})
+
+	t.Run("inherits parent factory and tags", func(t *testing.T) {
+		b := newContainerBuilder()
+		b.SetFactory("parent #abstract #private #priority=3 #custom=a", dummyFactory)
+		b.SetChild("child #custom=b", "parent", map[string]string{"other": "c"})
+
+		p := b.definitions["parent"]
+		d := b.definitions["child"]
+		assert.Equal(t, p, d.Parent)
+		assert.Equal(t, TagFactory, d.Kind)
+		assert.False(t, d.Abstract)
+		assert.True(t, d.Private)
+		assert.Equal(t, int16(3), d.Priority)
+		assert.Equal(t, "b", d.GetTag("custom"))
+		assert.Equal(t, "c", d.GetTag("other"))
+		assert.False(t, d.HasTag(TagAbstract))
+		assert.Equal(t, 1, d.Factory(nil))
+	})
+
+	t.Run("overrides injected fields", func(t *testing.T) {
+		type Handler struct {
+			Repo string `inject:"repo.default"`
+			Name string `inject:"name"`
+		}
+
+		b := NewContainerBuilder()
+		b.SetValue("repo.default", "default")
+		b.SetValue("repo.users", "users")
+		b.SetValue("name", "handler")
+		b.SetInjectable("handler #abstract", &Handler{})
+		b.SetChild("handler.default", "handler")
+		b.SetChild("handler.users #inject.Repo=repo.users", "handler")
+		c := b.GetContainer()
+
+		assert.Equal(t, &Handler{Repo: "default", Name: "handler"}, c.Get("handler.default"))
+		assert.Equal(t, &Handler{Repo: "users", Name: "handler"}, c.Get("handler.users"))
+		assert.False(t, b.GetDefinition("handler.users").HasTag("inject.Repo"))
+	})
+
+	for _, data := range []struct {
+		name   string
+		key    string
+		parent string
+		error  string
+	}{
+		{"panics if parent does not exist", "child", "none", "definition with id 'none' does not exist and child cannot be set"},
+		{"panics if overriding fields of not injectable", "child #inject.F1=x", "factory", "definition with id 'factory' is not injectable and child fields cannot be overridden"},
+		{"panics if overriding not injected field", "child #inject.F2=x", "injectable", "field 'F2' is not injected in Fields for key 'child'"},
+		{"panics if overriding unknown field", "child #inject.F3=x", "injectable", "field 'F3' is not injected in Fields for key 'child'"},
+	} {
+		t.Run(data.name, func(t *testing.T) {
+			type Fields struct {
+				F1 string `inject:"f1"`
+				F2 string
+			}
+
+			b := NewContainerBuilder()
+			b.SetFactory("factory", dummyFactory)
+			b.SetInjectable("injectable", Fields{})
+
+			assert.PanicsWithValue(t, data.error, func() {
+				b.SetChild(data.key, data.parent)
+			})
+		})
+	}
+}
+
+func TestContainerBuilder_SetSynthetic(t *testing.T) {
+	testSetMethodsCommon(t, TagSynthetic, func(b ContainerBuilder, key string, tags ...map[string]string) {
+		b.SetSynthetic(key, nil, tags...)
+	})
+
+	t.Run("declares the type", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetSynthetic("request #private", reflect.TypeOf(1))
+
+		assert.Equal(t, reflect.TypeOf(1), b.GetDefinition("request").GetType())
+		assert.Equal(t, TagSynthetic, b.GetDefinition("request").GetKind())
+		assert.True(t, b.GetDefinition("request").IsPrivate())
+	})
+}
+
 func TestContainerBuilder_SetAll(t *testing.T) {
 	t.Run("binds all kinds of definitions", func(t *testing.T) {
 		b := NewContainerBuilder()
@@ -285,6 +375,7 @@ func TestContainerBuilder_SetAll(t *testing.T) {
 			{Key: "param #value", Target: 1},
 			{Key: "alias #alias", Target: "service"},
 			{Key: "injectable #inject", Target: struct{}{}},
+			{Key: "synthetic #synthetic", Target: reflect.TypeOf("")},
 			{Key: "service2", Target: func(c Container) interface{} {
 				return c.Get("param").(int)
 			}},
@@ -295,11 +386,13 @@ func TestContainerBuilder_SetAll(t *testing.T) {
 		assert.True(t, b.HasDefinition("param"))
 		assert.True(t, b.HasDefinition("alias"))
 		assert.True(t, b.HasDefinition("service2"))
-		assert.Equal(t, "factory", b.GetDefinition("service").Kind)
-		assert.Equal(t, "inject", b.GetDefinition("injectable").Kind)
-		assert.Equal(t, "value", b.GetDefinition("param").Kind)
-		assert.Equal(t, "alias", b.GetDefinition("alias").Kind)
-		assert.Equal(t, "factory", b.GetDefinition("service2").Kind)
+		assert.Equal(t, "factory", b.GetDefinition("service").GetKind())
+		assert.Equal(t, "inject", b.GetDefinition("injectable").GetKind())
+		assert.Equal(t, "value", b.GetDefinition("param").GetKind())
+		assert.Equal(t, "alias", b.GetDefinition("alias").GetKind())
+		assert.Equal(t, "factory", b.GetDefinition("service2").GetKind())
+		assert.Equal(t, "synthetic", b.GetDefinition("synthetic").GetKind())
+		assert.Equal(t, reflect.TypeOf(""), b.GetDefinition("synthetic").GetType())
 	})
 
 	t.Run("panics", func(t *testing.T) {
@@ -312,7 +405,8 @@ func TestContainerBuilder_SetAll(t *testing.T) {
 			{"if invalid #priority=abc", "dummy #priority=abc", dummyFactory, "priority tag value 'abc' is not a valid number for key 'dummy'"},
 			{"if invalid #private=off", "dummy #private=off", dummyFactory, "private tag value 'off' is not a valid boolean for key 'dummy'"},
 			{"if invalid #shared=on", "dummy #shared=on", dummyFactory, "shared tag value 'on' is not a valid boolean for key 'dummy'"},
-			{"if overlapping kinds", "dummy #factory #value", dummyFactory, "tag 'value' can't be used simultaneously with [factory value alias inject] for key 'dummy'"},
+			{"if overlapping kinds", "dummy #factory #value", dummyFactory, "tag 'value' can't be used simultaneously with [factory value alias inject synthetic] for key 'dummy'"},
+			{"if malformed key", "dummy #=x", dummyFactory, "invalid key 'dummy #=x': empty tag name at position 7"},
 		}
 
 		b := NewContainerBuilder()
@@ -331,6 +425,23 @@ func TestContainerBuilder_SetAll(t *testing.T) {
 			b.SetAll(Binding{Key: "#factory", Target: 1})
 		})
 	})
+
+	t.Run("binds multi-valued tags", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetAll(
+			Binding{Key: "k1 #event=a #event=b #other=x #other=y", Target: 1, Tags: map[string]string{TagValue: "", "other": "z"}},
+			Binding{Key: "k2 #event=a #event=b", Target: 2, TagValues: map[string][]string{
+				TagValue: {""},
+				"event":  {"c", "d;priority=3"},
+			}},
+		)
+
+		assert.Equal(t, []string{"a", "b"}, b.GetDefinition("k1").GetTagValues("event"))
+		assert.Equal(t, []string{"z"}, b.GetDefinition("k1").GetTagValues("other"))
+		assert.Equal(t, []string{"c", "d"}, b.GetDefinition("k2").GetTagValues("event"))
+		assert.Equal(t, int16(3), b.GetDefinition("k2").GetTagPriority("event", "d"))
+		assert.Equal(t, "value", b.GetDefinition("k2").GetKind())
+	})
 }
 
 func TestContainerBuilder_GetTaggedKeys(t *testing.T) {
@@ -355,6 +466,14 @@ func TestContainerBuilder_GetTaggedKeys(t *testing.T) {
 		assert.Len(t, ds, 2)
 	})
 
+	t.Run("skips abstract definitions", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetFactory("k1 #tag #abstract", dummyFactory)
+		b.SetChild("k2", "k1")
+
+		assert.Equal(t, []string{"k2"}, b.GetTaggedKeys("tag", nil))
+	})
+
 	t.Run("get keys by tag and constrained values", func(t *testing.T) {
 		ds := b.GetTaggedKeys("tag", []string{"one"})
 		assert.Subset(t, []string{"key1"}, ds)
@@ -366,9 +485,129 @@ func TestContainerBuilder_GetTaggedKeys(t *testing.T) {
 	})
 }
 
+func TestContainerBuilder_GetTaggedKeysMultiValued(t *testing.T) {
+	b := newContainerBuilder()
+	b.SetFactory("on.created #event=user.created", dummyFactory)
+	b.SetFactory("on.any #event=user.created;priority=-1 #event=user.deleted;priority=10 #priority=5", dummyFactory)
+	b.SetFactory("on.deleted #event=user.deleted #priority=1", dummyFactory)
+
+	t.Run("matches any value", func(t *testing.T) {
+		assert.Equal(t, []string{"on.created", "on.any"}, b.GetTaggedKeys("event", []string{"user.created"}))
+		assert.Equal(t, []string{"on.any", "on.deleted"}, b.GetTaggedKeys("event", []string{"user.deleted"}))
+		assert.Equal(t, []string{"on.any", "on.deleted", "on.created"}, b.GetTaggedKeys("event", nil))
+	})
+
+	t.Run("sorts by priority of matching values", func(t *testing.T) {
+		c := b.resolve()
+
+		assert.Equal(t, []string{"on.created", "on.any"}, c.tags.keys("event", []string{"user.created"}))
+		assert.Equal(t, []string{"on.any", "on.deleted", "on.created"},
+			b.GetTaggedKeys("event", []string{"user.created", "user.deleted"}))
+		assert.Equal(t, b.GetTaggedKeys("event", []string{"user.created", "user.deleted"}),
+			c.tags.keys("event", []string{"user.deleted", "user.created"}))
+	})
+
+	t.Run("inherits all values on children", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetFactory("base #abstract #event=a;priority=2 #event=b #channel=x", dummyFactory)
+		b.SetChild("child #channel=y #channel=z", "base")
+
+		d := b.GetDefinition("child")
+		assert.Equal(t, []string{"a", "b"}, d.GetTagValues("event"))
+		assert.Equal(t, int16(2), d.GetTagPriority("event", "a"))
+		assert.Equal(t, []string{"y", "z"}, d.GetTagValues("channel"))
+	})
+}
+
+func TestContainerBuilder_SetDefinitionOrigin(t *testing.T) {
+	t.Run("records provider and source location", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("direct", 1)
+		b.AddProvider(ProviderFunc(func(b ContainerBuilder) {
+			b.SetAll(Binding{Key: "provided #value", Target: 1})
+		}))
+		b.AddResolver(ResolverFunc(func(b ContainerBuilder) {
+			b.SetAlias("resolved", "provided")
+		}))
+		b.GetContainer()
+
+		assert.Equal(t, "", b.GetDefinition("direct").GetProvider())
+		assert.Equal(t, "di.ProviderFunc", b.GetDefinition("provided").GetProvider())
+		assert.Equal(t, "di.ResolverFunc", b.GetDefinition("resolved").GetProvider())
+		for _, k := range []string{"direct", "provided", "resolved"} {
+			assert.Contains(t, b.GetDefinition(k).GetSource(), "container_builder_test.go:")
+		}
+	})
+
+	t.Run("keeps history of overwritten definitions", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("k", 1)
+		first := b.GetDefinition("k")
+		b.SetValue("k", 2)
+		second := b.GetDefinition("k")
+		b.SetValue("k", 3)
+
+		assert.Equal(t, []Definition{second, first}, b.GetHistory("k"))
+		assert.Empty(t, b.GetHistory("none"))
+	})
+
+	t.Run("allows overwriting from different providers if not strict", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("k", 1)
+		b.AddProvider(ProviderFunc(func(b ContainerBuilder) { b.SetValue("k", 2) }))
+
+		assert.NotPanics(t, func() {
+			b.GetContainer()
+		})
+	})
+
+	t.Run("panics overwriting from different providers if strict", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetStrict(true)
+		b.SetValue("k", 1)
+		b.AddProvider(ProviderFunc(func(b ContainerBuilder) { b.SetValue("k", 2) }))
+
+		defer func() {
+			msg := recover().(string)
+			assert.True(t, strings.HasPrefix(msg, "definition with id 'k' registered at "+b.GetDefinition("k").GetSource()))
+			assert.Contains(t, msg, "can't be overwritten from ")
+			assert.Equal(t, 2, strings.Count(msg, "container_builder_test.go:"))
+			assert.True(t, strings.HasSuffix(msg, " without the override tag"))
+		}()
+		b.GetContainer()
+	})
+
+	t.Run("allows overwriting if strict", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetStrict(true)
+		b.SetValue("k", 1)
+		b.SetValue("k", 2)
+		b.SetValue("a", 1)
+		b.SetAlias("alias", "a")
+		b.AddProvider(ProviderFunc(func(b ContainerBuilder) {
+			b.SetValue("k #override", 3)
+			b.SetValue("alias", 3)
+		}))
+
+		assert.NotPanics(t, func() {
+			b.GetContainer()
+		})
+		assert.Len(t, b.GetHistory("k"), 2)
+	})
+
+	t.Run("panics setting strict mode if resolved", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.GetContainer()
+
+		assert.PanicsWithValue(t, "container is resolved and new items can not be set", func() {
+			b.SetStrict(true)
+		})
+	})
+}
+
 func TestContainerBuilder_AddProvider(t *testing.T) {
 	t.Run("adds providers", func(t *testing.T) {
-		b := NewContainerBuilder()
+		b := newContainerBuilder()
 		b.AddProvider(dummyProvider, dummyProvider)
 
 		assert.Equal(t, 2, len(b.providers))
@@ -386,7 +625,7 @@ func TestContainerBuilder_AddProvider(t *testing.T) {
 
 func TestContainerBuilder_AddResolver(t *testing.T) {
 	t.Run("adds providers", func(t *testing.T) {
-		b := NewContainerBuilder()
+		b := newContainerBuilder()
 		b.AddResolver(dummyResolver, dummyResolver)
 
 		assert.Equal(t, 2, len(b.resolvers))
@@ -413,10 +652,10 @@ func TestContainerBuilder_GetContainer(t *testing.T) {
 			*spyResolve = true
 		})
 
-		b := NewContainerBuilder()
+		b := newContainerBuilder()
 		b.AddProvider(p1)
 		b.AddResolver(p2)
-		c := b.GetContainer()
+		c := b.resolve()
 
 		assert.True(t, b.resolved)
 		assert.True(t, *spyProvide)
@@ -425,13 +664,32 @@ func TestContainerBuilder_GetContainer(t *testing.T) {
 		assert.Empty(t, c.instances)
 	})
 
+	t.Run("returns a resolved container managing its services", func(t *testing.T) {
+		spy := 0
+		b := newContainerBuilder()
+		b.SetFactory("k #shared #tag", func(c Container) interface{} {
+			spy++
+			return spy
+		})
+
+		var c ResolvedContainer = b.GetContainer()
+		var l Lifecycle = c
+		l.MustBuild(false)
+		assert.Equal(t, 1, c.Get("k"))
+		l.Reset()
+		assert.Equal(t, []interface{}{2}, c.GetTaggedBy("tag"))
+		keys, err := c.QueryKeys("tag")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"k"}, keys)
+	})
+
 	t.Run("reuses resolved builder", func(t *testing.T) {
 		p1 := ProviderFunc(func(_ ContainerBuilder) {})
 
-		b := NewContainerBuilder()
+		b := newContainerBuilder()
 		b.AddProvider(p1)
-		c1 := b.GetContainer()
-		c2 := b.GetContainer()
+		c1 := b.resolve()
+		c2 := b.resolve()
 
 		assert.Same(t, b, c1.builder)
 		assert.Same(t, c1.builder, c2.builder)
@@ -6,6 +6,7 @@ package di
 
 import (
 	"github.com/stretchr/testify/assert"
+	"reflect"
 	"testing"
 )
 
@@ -95,6 +96,20 @@ func TestContainer_Get(t *testing.T) {
 		})
 	})
 
+	t.Run("panics if requesting abstract service", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetFactory("a #abstract", func(cb Container) interface{} { return 1 })
+		b.SetFactory("b", func(cb Container) interface{} { return cb.Get("a") })
+		c := b.GetContainer()
+
+		assert.PanicsWithValue(t, "service with key 'a' is abstract and can't be retrieved from the container", func() {
+			_ = c.Get("a")
+		})
+		assert.PanicsWithValue(t, "service with key 'a' is abstract and can't be retrieved from the container", func() {
+			_ = c.Get("b")
+		})
+	})
+
 	t.Run("panics if requesting private alias", func(t *testing.T) {
 		spy := 0
 		newA := func(cb Container) interface{} { spy++; return spy }
@@ -342,6 +357,78 @@ func TestContainer_GetTaggedBy(t *testing.T) {
 	})
 }
 
+func TestContainer_Provide(t *testing.T) {
+	type Request struct {
+		Path string
+	}
+	type Handler struct {
+		Request *Request `inject:"request"`
+	}
+
+	newBuilder := func() *containerBuilder {
+		b := newContainerBuilder()
+		b.SetSynthetic("request", reflect.TypeOf(&Request{}))
+		b.SetSynthetic("args", nil)
+		b.SetInjectable("handler", Handler{})
+		b.SetFactory("path", func(c Container) interface{} {
+			return c.Get("request").(*Request).Path
+		})
+		b.SetValue("value", 1)
+		return b
+	}
+
+	t.Run("provides synthetic services to dependencies", func(t *testing.T) {
+		c := newBuilder().GetContainer()
+		r := &Request{Path: "/"}
+		c.Provide("request", r)
+		c.Provide("args", []string{"-v"})
+
+		assert.Equal(t, r, c.Get("request"))
+		assert.Equal(t, Handler{Request: r}, c.Get("handler"))
+		assert.Equal(t, "/", c.Get("path"))
+		assert.Equal(t, []string{"-v"}, c.Get("args"))
+	})
+
+	t.Run("provides values per container", func(t *testing.T) {
+		b := newBuilder()
+		c1 := b.GetContainer()
+		c2 := b.GetContainer()
+		c1.Provide("request", &Request{Path: "/1"})
+		c2.Provide("request", &Request{Path: "/2"})
+
+		assert.Equal(t, "/1", c1.Get("path"))
+		assert.Equal(t, "/2", c2.Get("path"))
+	})
+
+	t.Run("panics if requested before provided", func(t *testing.T) {
+		c := newBuilder().GetContainer()
+
+		assert.PanicsWithValue(t, "synthetic service with key 'request' has not been provided to the container", func() {
+			c.Get("handler")
+		})
+	})
+
+	for _, data := range []struct {
+		name  string
+		key   string
+		value interface{}
+		error string
+	}{
+		{"panics if not synthetic", "value", 1, "service with key 'value' is not synthetic and can't be provided"},
+		{"panics if not found", "none", 1, "service with key 'none' is not synthetic and can't be provided"},
+		{"panics if invalid type", "request", Request{}, "value of type di.Request can't be provided for synthetic service with key 'request' of type *di.Request"},
+		{"panics if nil value", "request", nil, "value of type <nil> can't be provided for synthetic service with key 'request' of type *di.Request"},
+	} {
+		t.Run(data.name, func(t *testing.T) {
+			c := newBuilder().GetContainer()
+
+			assert.PanicsWithValue(t, data.error, func() {
+				c.Provide(data.key, data.value)
+			})
+		})
+	}
+}
+
 func TestContainer_MustBuild(t *testing.T) {
 	t.Run("panics if invalid service", func(t *testing.T) {
 		s1 := func(c Container) interface{} {
@@ -372,10 +459,10 @@ func TestContainer_MustBuild(t *testing.T) {
 			return &value
 		}
 
-		b := NewContainerBuilder()
+		b := newContainerBuilder()
 		b.SetFactory("s1", s1)
 		b.SetFactory("s2", s2)
-		c := b.GetContainer()
+		c := b.resolve()
 
 		c.MustBuild(true)
 
@@ -392,10 +479,10 @@ func TestContainer_MustBuild(t *testing.T) {
 			return &value
 		}
 
-		b := NewContainerBuilder()
+		b := newContainerBuilder()
 		b.SetFactory("s1", s1)
 		b.SetFactory("s2 #shared #private", s2)
-		c := b.GetContainer()
+		c := b.resolve()
 
 		c.MustBuild(false)
 
@@ -0,0 +1,199 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Debugger is the command returned by DebugCommand, which lists and inspects the definitions of a builder.
+type Debugger struct {
+	builder *containerBuilder
+	err     error
+}
+
+// DebugCommand returns a command to list and inspect the definitions of the given builder. It is meant to be run from
+// the application's own main with the command line arguments, e.g. di.DebugCommand(b).Run(os.Args[1:], os.Stdout).
+// The builder is resolved when the command runs. Supported flags are:
+//
+//	--tag=name or --tag=name=value: lists only definitions with the given tag, or tag and value.
+//	--key=glob: lists only definitions whose key matches the glob pattern, as in path.Match.
+//	--show=key: prints the details of a single definition, including its dependencies and dependents.
+//
+// Only builders returned by NewContainerBuilder are supported, running the command with other implementations returns
+// an error.
+func DebugCommand(builder ContainerBuilder) *Debugger {
+	b, ok := builder.(*containerBuilder)
+	if !ok {
+		return &Debugger{err: fmt.Errorf("builder of type %T is not supported", builder)}
+	}
+
+	return &Debugger{builder: b}
+}
+
+// Run parses the given arguments and writes the requested information to out.
+func (d *Debugger) Run(args []string, out io.Writer) error {
+	if d.err != nil {
+		return d.err
+	}
+
+	flags := flag.NewFlagSet("di-debug", flag.ContinueOnError)
+	flags.SetOutput(out)
+	tag := flags.String("tag", "", "list only definitions with the tag, or tag=value")
+	glob := flags.String("key", "", "list only definitions whose key matches the glob pattern")
+	show := flags.String("show", "", "show the details of the definition with the key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := path.Match(*glob, ""); err != nil {
+		return fmt.Errorf("invalid key pattern '%s': %s", *glob, err)
+	}
+
+	c := d.builder.GetContainer()
+	if *show != "" {
+		return d.show(c, *show, out)
+	}
+
+	return d.list(*tag, *glob, out)
+}
+
+// list writes a table with the definitions matching the given tag and key glob, sorted by key.
+func (d *Debugger) list(tag, glob string, out io.Writer) error {
+	tagName, tagValue, byValue := tag, "", false
+	if i := strings.Index(tag, "="); i >= 0 {
+		tagName, tagValue, byValue = tag[:i], tag[i+1:], true
+	}
+
+	keys := d.keys()
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tKIND\tSHARED\tPRIVATE\tTAGS\tALIAS OF\tTYPE\tPROVIDER")
+	for _, key := range keys {
+		def := d.builder.definitions[key]
+		if glob != "" {
+			if ok, _ := path.Match(glob, key); !ok {
+				continue
+			}
+		}
+
+		if tag != "" && (!def.HasTag(tagName) || byValue && !def.HasTagValue(tagName, tagValue)) {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%t\t%t\t%s\t%s\t%s\t%s\n", key, def.Kind, def.Shared, def.Private,
+			orDash(formatTags(def)), orDash(keyOf(def.AliasOf)), orDash(declaredType(def)), orDash(def.Provider))
+	}
+
+	return w.Flush()
+}
+
+// show writes the details of the definition on the given key.
+func (d *Debugger) show(c ResolvedContainer, key string, out io.Writer) error {
+	def, ok := d.builder.definitions[key]
+	if !ok {
+		return fmt.Errorf("definition with id '%s' does not exist", key)
+	}
+
+	dependencies := make([]string, 0)
+	dependents := make([]string, 0)
+	for _, e := range c.Graph().Edges {
+		if e.From == key {
+			dependencies = append(dependencies, fmt.Sprintf("%s (%s)", e.To, e.Kind))
+		}
+		if e.To == key {
+			dependents = append(dependents, fmt.Sprintf("%s (%s)", e.From, e.Kind))
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Key\t%s\n", key)
+	fmt.Fprintf(w, "Kind\t%s\n", def.Kind)
+	fmt.Fprintf(w, "Shared\t%t\n", def.Shared)
+	fmt.Fprintf(w, "Private\t%t\n", def.Private)
+	fmt.Fprintf(w, "Abstract\t%t\n", def.Abstract)
+	fmt.Fprintf(w, "Priority\t%d\n", def.Priority)
+	fmt.Fprintf(w, "Tags\t%s\n", orDash(formatTags(def)))
+	fmt.Fprintf(w, "Alias of\t%s\n", orDash(keyOf(def.AliasOf)))
+	fmt.Fprintf(w, "Parent\t%s\n", orDash(keyOf(def.Parent)))
+	fmt.Fprintf(w, "Type\t%s\n", orDash(declaredType(def)))
+	fmt.Fprintf(w, "Provider\t%s\n", orDash(def.Provider))
+	fmt.Fprintf(w, "Source\t%s\n", orDash(def.Source))
+	for _, o := range d.builder.GetHistory(key) {
+		fmt.Fprintf(w, "Overwrites\t%s at %s\n", orDash(o.GetProvider()), orDash(o.GetSource()))
+	}
+	fmt.Fprintf(w, "Dependencies\t%s\n", orDash(strings.Join(dependencies, ", ")))
+	fmt.Fprintf(w, "Dependents\t%s\n", orDash(strings.Join(dependents, ", ")))
+
+	return w.Flush()
+}
+
+// keys returns the sorted keys of the builder definitions.
+func (d *Debugger) keys() []string {
+	keys := make([]string, 0, len(d.builder.definitions))
+	for key := range d.builder.definitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// keyOf returns the key of the given definition, or an empty string if there is none, to print alias targets and
+// parents.
+func keyOf(def *definition) string {
+	if def == nil {
+		return ""
+	}
+
+	return def.key
+}
+
+// formatTags returns the sorted tags of a definition using the key syntax, omitting the kind tag.
+func formatTags(def *definition) string {
+	tags := make([]string, 0, len(def.Tags))
+	for t := range def.Tags {
+		if t == def.Kind {
+			continue
+		}
+		for _, v := range def.GetTagValues(t) {
+			tag := quoteKeyPart(t)
+			if v != "" {
+				tag += "=" + quoteKeyPart(v)
+			}
+			if p, ok := def.TagPriorities[t][v]; ok {
+				tag += fmt.Sprintf(";%s=%d", TagPriority, p)
+			}
+			tags = append(tags, "#"+tag)
+		}
+	}
+	sort.Strings(tags)
+
+	return strings.Join(tags, " ")
+}
+
+// declaredType returns the name of the type of the service built by a definition when it is known without building it,
+// see GetType.
+func declaredType(def *definition) string {
+	if t := def.GetType(); t != nil {
+		return t.String()
+	}
+
+	return ""
+}
+
+// orDash returns the given string or a dash if it's empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type debugMailer struct {
+	From string `inject:"email.from"`
+}
+
+func newDebugBuilder() *containerBuilder {
+	b := newContainerBuilder()
+	b.SetValue("email.from #private", "me")
+	b.SetFactory("email.mailer", dummyFactory)
+	b.SetInjectable("email.mailer #shared #channel=email", &debugMailer{})
+	b.SetSynthetic("request", reflect.TypeOf(""))
+	b.AddProvider(ProviderFunc(func(b ContainerBuilder) {
+		b.SetAlias("mailer", "email.mailer")
+	}))
+
+	return b
+}
+
+func TestDebugCommand_Run(t *testing.T) {
+	for _, data := range []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"lists all definitions", []string{}, `KEY           KIND       SHARED  PRIVATE  TAGS                    ALIAS OF      TYPE             PROVIDER
+email.from    value      false   true     #private                -             string           -
+email.mailer  inject     true    false    #channel=email #shared  -             *di.debugMailer  -
+mailer        alias      false   false    -                       email.mailer  *di.debugMailer  di.ProviderFunc
+request       synthetic  false   false    -                       -             string           -
+`},
+		{"filters by key glob", []string{"--key=email.*"}, `KEY           KIND    SHARED  PRIVATE  TAGS                    ALIAS OF  TYPE             PROVIDER
+email.from    value   false   true     #private                -         string           -
+email.mailer  inject  true    false    #channel=email #shared  -         *di.debugMailer  -
+`},
+		{"filters by tag", []string{"--tag=private"}, `KEY         KIND   SHARED  PRIVATE  TAGS      ALIAS OF  TYPE    PROVIDER
+email.from  value  false   true     #private  -         string  -
+`},
+		{"filters by tag value", []string{"--tag=channel=sms"}, `KEY  KIND  SHARED  PRIVATE  TAGS  ALIAS OF  TYPE  PROVIDER
+`},
+		{"shows a definition", []string{"--show=email.mailer"}, `Key           email.mailer
+Kind          inject
+Shared        true
+Private       false
+Abstract      false
+Priority      0
+Tags          #channel=email #shared
+Alias of      -
+Parent        -
+Type          *di.debugMailer
+Provider      -
+Source        {source}
+Overwrites    - at {overwritten}
+Dependencies  email.from (inject)
+Dependents    mailer (alias)
+`},
+	} {
+		t.Run(data.name, func(t *testing.T) {
+			b := newDebugBuilder()
+			expected := strings.Replace(data.expected, "{source}", b.GetDefinition("email.mailer").GetSource(), 1)
+			expected = strings.Replace(expected, "{overwritten}", b.GetHistory("email.mailer")[0].GetSource(), 1)
+
+			out := &bytes.Buffer{}
+			err := DebugCommand(b).Run(data.args, out)
+
+			assert.Nil(t, err)
+			assert.Equal(t, expected, out.String())
+		})
+	}
+
+	for _, data := range []struct {
+		name  string
+		args  []string
+		error string
+	}{
+		{"fails if definition not found", []string{"--show=none"}, "definition with id 'none' does not exist"},
+		{"fails if invalid glob", []string{"--key=["}, "invalid key pattern '[': syntax error in pattern"},
+		{"fails if unknown flag", []string{"--other"}, "flag provided but not defined: -other"},
+	} {
+		t.Run(data.name, func(t *testing.T) {
+			err := DebugCommand(newDebugBuilder()).Run(data.args, &bytes.Buffer{})
+			assert.EqualError(t, err, data.error)
+		})
+	}
+
+	t.Run("fails if builder is not supported", func(t *testing.T) {
+		err := DebugCommand(struct{ ContainerBuilder }{}).Run(nil, &bytes.Buffer{})
+		assert.EqualError(t, err, "builder of type struct { di.ContainerBuilder } is not supported")
+	})
+
+	t.Run("prints the keys of overwritten targets", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("target", 1)
+		b.SetAlias("alias", "target")
+		b.SetValue("target", 2)
+
+		out := &bytes.Buffer{}
+		err := DebugCommand(b).Run([]string{"--key=alias"}, out)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `KEY    KIND   SHARED  PRIVATE  TAGS  ALIAS OF  TYPE  PROVIDER
+alias  alias  false   false    -     target    int   -
+`, out.String())
+	})
+
+	t.Run("lists and filters multi-valued tags", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("sender #channel=sms #channel=push;priority=2", 1)
+		b.SetValue("mailer #channel=email", 2)
+
+		out := &bytes.Buffer{}
+		err := DebugCommand(b).Run([]string{"--tag=channel=push"}, out)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `KEY     KIND   SHARED  PRIVATE  TAGS                                   ALIAS OF  TYPE  PROVIDER
+sender  value  false   false    #channel=push;priority=2 #channel=sms  -         int   -
+`, out.String())
+	})
+}
@@ -6,96 +6,371 @@ package di
 
 import (
 	"fmt"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // kindTags are the list of reserved tags that represent valid kinds of service definitions.
-var kindTags = []string{TagFactory, TagValue, TagAlias, TagInject}
-
-// keyRex is the regular expression used to parse service keys.
-var keyRex = regexp.MustCompile(`^[^#]+|#([^#=]+)|=([^#]+)`)
-
-// parseKey looks for tags in the given key. Tags can be specified using the '#' char as separator. The value for a tag
-// can be defined by using the '=' char as separator from the tag name and its value. The real key will be the suffix
-// of the given key until the first '#'. This function will trim empty spaces of the found key, tag names and valeus.
-// As an Example:
-//
-// 	" some.suffix #tag1 = 2 # tag2"
-//
-// Will output:
-// 	key  = "some.suffix"
-// 	tags = {"tag1": "2", "tag2": ""}
-func parseKey(raw string) (key string, tags map[string]string) {
-	tags = map[string]string{}
-
-	matches := keyRex.FindAllStringSubmatch(raw, -1)
-
-	for i := 0; i < len(matches); i++ {
-
-		if strings.HasPrefix(matches[i][0], "#") {
-			tags[strings.TrimSpace(matches[i][1])] = ""
-			continue
-		}
-
-		if i == 0 {
-			key = strings.TrimSpace(matches[i][0])
-			continue
-		}
+var kindTags = []string{TagFactory, TagValue, TagAlias, TagInject, TagSynthetic}
+
+// tagPriorityRex is the regular expression used to parse the priority attribute of tag values.
+var tagPriorityRex = regexp.MustCompile(`^(.*?)\s*;\s*priority\s*=\s*([-+]?\d+)$`)
+
+// parseTagValue splits a tag value from its optional priority attribute, which gives the service a specific priority
+// when it is retrieved by that tag value. As an Example, "user.created;priority=10" outputs the value "user.created"
+// and the priority 10. It returns an error if the priority is not a valid int16 number.
+func parseTagValue(raw string) (value string, priority int16, ok bool, err error) {
+	m := tagPriorityRex.FindStringSubmatch(raw)
+	if m == nil {
+		return raw, 0, false, nil
+	}
 
-		tags[strings.TrimSpace(matches[i-1][1])] = strings.TrimSpace(matches[i][2])
+	p, err := strconv.ParseInt(m[2], 10, 16)
+	if err != nil {
+		return "", 0, false, fmt.Errorf("priority of tag value '%s' is not a valid number", raw)
 	}
 
-	return key, tags
+	return m[1], int16(p), true, nil
 }
 
-
 // definition represents a service factory with required metadata by the container to build
 // the service instance and manage its dependencies and behaviour.
 type definition struct {
-	Factory  func(Container) interface{}
-	Tags     map[string]string
-	AliasOf  *definition
-	Priority int16
-	Shared   bool
-	Private  bool
-	Kind     string
+	key           string
+	Factory       func(Container) interface{}
+	Tags          map[string]string
+	TagValues     map[string][]string
+	TagPriorities map[string]map[string]int16
+	AliasOf       *definition
+	Parent        *definition
+	Injection     *injection
+	Type          reflect.Type
+	Locator       []string
+	Provider      string
+	Source        string
+	Overwrites    *definition
+	origin        int
+	schemas       *tagSchemas
+	Conditions    []Condition
+	Priority      int16
+	Shared        bool
+	Private       bool
+	Abstract      bool
+	Kind          string
+}
+
+// Definition is the read-only view of a service definition returned by the builder setters and GetDefinition, so it
+// can be inspected without building the service.
+type Definition interface {
+	GetKey() string
+	GetKind() string
+	GetTags() map[string]string
+	HasTag(tag string) bool
+	GetTag(tag string, alt ...string) string
+	GetTagValues(tag string) []string
+	HasTagValue(tag, value string) bool
+	GetTagPriority(tag, value string) int16
+	TagString(tag string) string
+	TagInt(tag string) int
+	TagBool(tag string) bool
+	TagDuration(tag string) time.Duration
+	GetAliasOf() Definition
+	GetPriority() int16
+	IsShared() bool
+	IsPrivate() bool
+	IsAbstract() bool
+	GetType() reflect.Type
+	GetInjections() map[string]string
+	GetFactory() func(Container) interface{}
+	GetProvider() string
+	GetSource() string
+}
+
+// GetKey returns the key the definition is registered on.
+func (d *definition) GetKey() string {
+	return d.key
+}
+
+// GetKind returns the kind tag of the definition: factory, value, inject, alias or synthetic.
+func (d *definition) GetKind() string {
+	return d.Kind
+}
+
+// GetTags returns a copy of the tags of the definition, with the first value of the multi-valued ones.
+func (d *definition) GetTags() map[string]string {
+	tags := make(map[string]string, len(d.Tags))
+	for t, v := range d.Tags {
+		tags[t] = v
+	}
+
+	return tags
+}
+
+// GetAliasOf returns the definition aliased by current one, or nil if it is not an alias.
+func (d *definition) GetAliasOf() Definition {
+	if d.AliasOf == nil {
+		return nil
+	}
+
+	return d.AliasOf
+}
+
+// GetPriority returns the priority of the definition, used to sort tagged services.
+func (d *definition) GetPriority() int16 {
+	return d.Priority
+}
+
+// IsShared returns if the service is built once and shared by all retrievals.
+func (d *definition) IsShared() bool {
+	return d.Shared
+}
+
+// IsPrivate returns if the service can only be retrieved as a dependency of other services.
+func (d *definition) IsPrivate() bool {
+	return d.Private
+}
+
+// IsAbstract returns if the definition can only be used as the parent of other definitions.
+func (d *definition) IsAbstract() bool {
+	return d.Abstract
+}
+
+// GetType returns the type of the service built by the definition when it is known without building it: the declared
+// type of synthetic services, the struct of injectables, the type of values or the one of the aliased service. It
+// returns nil otherwise.
+func (d *definition) GetType() reflect.Type {
+	switch {
+	case d.Type != nil:
+		return d.Type
+	case d.Injection != nil && d.Injection.IsPtr:
+		return reflect.PtrTo(d.Injection.Type)
+	case d.Injection != nil:
+		return d.Injection.Type
+	case d.AliasOf != nil:
+		return d.AliasOf.GetType()
+	case d.Kind == TagValue:
+		if v := d.Factory(nil); v != nil {
+			return reflect.TypeOf(v)
+		}
+	}
+
+	return nil
+}
+
+// GetInjections returns the keys of the services injected on the fields of injectables indexed by field name, or nil
+// if the definition is not an injectable.
+func (d *definition) GetInjections() map[string]string {
+	if d.Injection == nil {
+		return nil
+	}
+
+	fields := make(map[string]string, len(d.Injection.Fields))
+	for f, k := range d.Injection.Fields {
+		fields[d.Injection.Type.Field(f).Name] = k
+	}
+
+	return fields
+}
+
+// GetFactory returns the function building the service.
+func (d *definition) GetFactory() func(Container) interface{} {
+	return d.Factory
+}
+
+// GetProvider returns the type of the provider or resolver which registered the definition, or an empty string if it
+// was registered directly.
+func (d *definition) GetProvider() string {
+	return d.Provider
+}
+
+// GetSource returns the source location where the definition was registered.
+func (d *definition) GetSource() string {
+	return d.Source
+}
+
+// injection holds the metadata of an injectable struct definition: the struct type, whether instances are returned as
+// pointers and the mapping of field indexes to the keys of the services to inject on them.
+type injection struct {
+	Type   reflect.Type
+	IsPtr  bool
+	Fields map[int]string
+}
+
+// Factory returns a service factory which creates a new struct of the injection type and sets its fields with the
+// services retrieved from the container, or with a locator if the field key has the locator prefix.
+func (i *injection) Factory() func(Container) interface{} {
+	return func(c Container) interface{} {
+		t := reflect.New(i.Type)
+		e := t.Elem()
+		for f, k := range i.Fields {
+			var p interface{}
+			if strings.HasPrefix(k, locatorPrefix) {
+				p = newLocator(c.(*container), parseLocatorEntries(k))
+			} else {
+				p = c.Get(k)
+			}
+			v := reflect.ValueOf(p)
+			e.Field(f).Set(v)
+		}
+
+		if i.IsPtr {
+			return t.Interface()
+		}
+
+		return e.Interface()
+	}
 }
 
-// newDefinition returns a new definition pointer
-func newDefinition(factory func(c Container) interface{}, tagsList ...map[string]string) (*definition, error) {
+// override returns a copy of the injection replacing the keys of the fields found in the given map, which is indexed
+// by field name. It returns an error if some field name is not an injected field of the struct.
+func (i *injection) override(keys map[string]string) (*injection, error) {
+	fields := make(map[int]string, len(i.Fields))
+	for f, k := range i.Fields {
+		fields[f] = k
+	}
+
+	for name, key := range keys {
+		f, ok := i.Type.FieldByName(name)
+		if !ok || len(f.Index) > 1 {
+			return nil, fmt.Errorf("field '%s' is not injected in %s", name, i.Type.Name())
+		}
+
+		if _, ok := fields[f.Index[0]]; !ok {
+			return nil, fmt.Errorf("field '%s' is not injected in %s", name, i.Type.Name())
+		}
+		fields[f.Index[0]] = key
+	}
+
+	return &injection{Type: i.Type, IsPtr: i.IsPtr, Fields: fields}, nil
+}
+
+// newDefinition returns a new definition pointer, whose tag values are validated with the given schemas, if any.
+func newDefinition(schemas *tagSchemas, factory func(c Container) interface{}, tagsList ...map[string]string) (*definition, error) {
 
 	tags := mergeTags(tagsList...)
 
-	priority, err := parseIntegerTag(TagPriority, tags)
-	if err != nil {
+	d := &definition{
+		schemas:       schemas,
+		Factory:       factory,
+		Tags:          tags,
+		TagValues:     make(map[string][]string, len(tags)),
+		TagPriorities: make(map[string]map[string]int16),
+	}
+	for tag, value := range tags {
+		if err := d.setTagValues(tag, []string{value}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.parseTags(); err != nil {
 		return nil, err
 	}
 
-	shared, err := parseBoolTag(TagShared, tags)
+	return d, nil
+}
+
+// parseTags sets the priority, flags and kind of current definition from its reserved tags, keeping them unchanged if
+// some tag is not valid.
+func (d *definition) parseTags() error {
+	priority, err := parseIntegerTag(TagPriority, d.Tags)
+	if err != nil {
+		return err
+	}
+
+	shared, err := parseBoolTag(TagShared, d.Tags)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	private, err := parseBoolTag(TagPrivate, tags)
+	private, err := parseBoolTag(TagPrivate, d.Tags)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	kind, err := selectKindTag(tags)
+	abstract, err := parseBoolTag(TagAbstract, d.Tags)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	kind, err := selectKindTag(d.Tags)
+	if err != nil {
+		return err
+	}
+
+	d.Priority = priority
+	d.Shared = shared
+	d.Private = private
+	d.Abstract = abstract
+	d.Kind = kind
+
+	return nil
+}
+
+// setTagValues replaces the values of the given tag, removing duplicates and parsing their priority attributes. The
+// first value is also kept in Tags. It returns an error if some priority attribute is not valid, or if some value is
+// not valid for the schema registered for the tag on the builder of the definition, see RegisterTag.
+func (d *definition) setTagValues(tag string, raw []string) error {
+	values := make([]string, 0, len(raw))
+	priorities := make(map[string]int16)
+	for _, r := range raw {
+		v, p, ok, err := parseTagValue(r)
+		if err != nil {
+			return err
+		}
+		if err := d.schemas.validate(tag, v); err != nil {
+			return err
+		}
+		if ok {
+			priorities[v] = p
+		}
+		if !inStrings(v, values) {
+			values = append(values, v)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	d.Tags[tag] = values[0]
+	d.TagValues[tag] = values
+	delete(d.TagPriorities, tag)
+	if len(priorities) > 0 {
+		d.TagPriorities[tag] = priorities
 	}
 
-	return &definition{
-		Factory:  factory,
-		Tags:     tags,
-		Priority: priority,
-		Shared:   shared,
-		Private:  private,
-		Kind:     kind,
-	}, nil
+	return nil
+}
+
+// copyTag copies the values of the given tag and their priorities from another definition.
+func (d *definition) copyTag(from *definition, tag string) {
+	values := from.GetTagValues(tag)
+	if len(values) == 0 {
+		return
+	}
+
+	d.Tags[tag] = values[0]
+	d.TagValues[tag] = append([]string(nil), values...)
+	delete(d.TagPriorities, tag)
+	if p, ok := from.TagPriorities[tag]; ok {
+		d.TagPriorities[tag] = make(map[string]int16, len(p))
+		for v, priority := range p {
+			d.TagPriorities[tag][v] = priority
+		}
+	}
+}
+
+// inStrings returns if the given string is in the slice.
+func inStrings(s string, slice []string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 // HasTag returns if current definition has a given tag.
@@ -111,13 +386,64 @@ func (d *definition) GetTag(tag string, alt ...string) string {
 		return v
 	}
 
-	if len(alt) > 0{
+	if len(alt) > 0 {
 		return alt[0]
 	}
 
 	return ""
 }
 
+// GetTagValues returns all the values of a given tag in order of declaration, or nil if definition doesn't have the tag.
+// The returned slice must not be modified.
+func (d *definition) GetTagValues(tag string) []string {
+	if values, ok := d.TagValues[tag]; ok {
+		return values
+	}
+
+	if v, ok := d.Tags[tag]; ok {
+		return []string{v}
+	}
+
+	return nil
+}
+
+// HasTagValue returns if current definition has a given tag with the given value among its values.
+func (d *definition) HasTagValue(tag, value string) bool {
+	return inStrings(value, d.GetTagValues(tag))
+}
+
+// GetTagPriority returns the priority of current definition when retrieved by the given tag value, which is the
+// priority attribute of the value if set, or the definition's priority otherwise.
+func (d *definition) GetTagPriority(tag, value string) int16 {
+	if p, ok := d.TagPriorities[tag][value]; ok {
+		return p
+	}
+
+	return d.Priority
+}
+
+// taggedPriority returns the priority of current definition when retrieved by the given tag and values, which is the
+// highest priority among the matching values, or the definition's priority if no values are given.
+func (d *definition) taggedPriority(tag string, values []string) int16 {
+	if len(values) == 0 {
+		return d.Priority
+	}
+
+	found := false
+	priority := d.Priority
+	for _, v := range values {
+		if !d.HasTagValue(tag, v) {
+			continue
+		}
+		if p := d.GetTagPriority(tag, v); !found || p > priority {
+			priority = p
+		}
+		found = true
+	}
+
+	return priority
+}
+
 // parseBoolTag looks for a given tag name in tags and returns the corresponding boolean value.
 // It returns "true" by default if tag has empty value, but it returns an error if tag value can not be parsed.
 func parseBoolTag(tagName string, tags map[string]string) (bool, error) {
@@ -7,6 +7,7 @@ package di
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"reflect"
 	"testing"
 )
 
@@ -14,35 +15,36 @@ func dummyFactory(_ Container) interface{} {
 	return 1
 }
 
-func TestParseKey(t *testing.T) {
+func TestParseTagValue(t *testing.T) {
 	tests := []struct {
-		raw  string
-		key  string
-		tags map[string]string
+		raw      string
+		value    string
+		priority int16
+		ok       bool
 	}{
-		{"", "", map[string]string{}},
-		{"Key", "Key", map[string]string{}},
-		{" Key ", "Key", map[string]string{}},
-		{" Key #tag1", "Key", map[string]string{"tag1": ""}},
-		{" Key #tag1 #tag2", "Key", map[string]string{"tag1": "", "tag2": ""}},
-		{" Key #tag1=1a #tag2=2b ", "Key", map[string]string{"tag1": "1a", "tag2": "2b"}},
-		{" Key #tag1=1a #tag2=2b cc", "Key", map[string]string{"tag1": "1a", "tag2": "2b cc"}},
-		{" Key #tag1=1a #tag2=2b cc ", "Key", map[string]string{"tag1": "1a", "tag2": "2b cc"}},
-		{" Key #tag1 #tag2 3 4 ", "Key", map[string]string{"tag1": "", "tag2 3 4": ""}},
-		{" Key #tag1 =1a #tag2 = 2b ", "Key", map[string]string{"tag1": "1a", "tag2": "2b"}},
-		{" Key #tag1 = #tag2 =", "Key", map[string]string{"tag1": "", "tag2": ""}},
-		{" some.suffix #tag1 = 2 # tag2", "some.suffix", map[string]string{"tag1": "2", "tag2": ""}},
-		{"#tag1", "", map[string]string{"tag1": ""}},
-		{"=tag1", "=tag1", map[string]string{}},
+		{"", "", 0, false},
+		{"user.created", "user.created", 0, false},
+		{"user.created;priority=10", "user.created", 10, true},
+		{"user.created ; priority = -3", "user.created", -3, true},
+		{";priority=+2", "", 2, true},
+		{"a;b", "a;b", 0, false},
+		{"a;priority=x", "a;priority=x", 0, false},
 	}
 
 	for _, data := range tests {
 		t.Run(data.raw, func(t *testing.T) {
-			key, tags := parseKey(data.raw)
-			assert.Equal(t, data.key, key)
-			assert.Equal(t, data.tags, tags)
+			value, priority, ok, err := parseTagValue(data.raw)
+			assert.Nil(t, err)
+			assert.Equal(t, data.value, value)
+			assert.Equal(t, data.priority, priority)
+			assert.Equal(t, data.ok, ok)
 		})
 	}
+
+	t.Run("fails on invalid priorities", func(t *testing.T) {
+		_, _, _, err := parseTagValue("a;priority=40000")
+		assert.EqualError(t, err, "priority of tag value 'a;priority=40000' is not a valid number")
+	})
 }
 
 func TestParseBoolTag(t *testing.T) {
@@ -137,7 +139,7 @@ func TestMergeTags(t *testing.T) {
 
 func TestNewDefinition(t *testing.T) {
 	t.Run("is created with empty tags", func(t *testing.T) {
-		def, _ := newDefinition(dummyFactory)
+		def, _ := newDefinition(nil, dummyFactory)
 		assert.Equal(t, false, def.Shared)
 		assert.Equal(t, false, def.Private)
 		assert.Equal(t, int16(0), def.Priority)
@@ -153,7 +155,7 @@ func TestNewDefinition(t *testing.T) {
 			TagShared:   "1",
 			TagPriority: "9",
 		}
-		def, _ := newDefinition(dummyFactory, custom)
+		def, _ := newDefinition(nil, dummyFactory, custom)
 
 		assert.Equal(t, true, def.Shared)
 		assert.Equal(t, true, def.Private)
@@ -171,13 +173,14 @@ func TestNewDefinition(t *testing.T) {
 		}{
 			{"if invalid priority value", map[string]string{TagPriority: "abc"}, "priority tag value 'abc' is not a valid number"},
 			{"if invalid private value", map[string]string{TagPrivate: "off"}, "private tag value 'off' is not a valid boolean"},
+			{"if invalid abstract value", map[string]string{TagAbstract: "yes"}, "abstract tag value 'yes' is not a valid boolean"},
 			{"if invalid shared value", map[string]string{TagShared: "on"}, "shared tag value 'on' is not a valid boolean"},
-			{"if multiple kind tags", map[string]string{TagFactory: "", TagValue: ""}, "tag 'value' can't be used simultaneously with [factory value alias inject]"},
+			{"if multiple kind tags", map[string]string{TagFactory: "", TagValue: ""}, "tag 'value' can't be used simultaneously with [factory value alias inject synthetic]"},
 		}
 
 		for _, data := range testData {
 			t.Run(data.name, func(t *testing.T) {
-				_, err := newDefinition(dummyFactory, data.tags)
+				_, err := newDefinition(nil, dummyFactory, data.tags)
 				assert.Equal(t, data.error, err.Error())
 			})
 		}
@@ -185,7 +188,7 @@ func TestNewDefinition(t *testing.T) {
 }
 
 func TestDefinition_HasTag(t *testing.T) {
-	def, _ := newDefinition(dummyFactory, map[string]string{"exists": "abc"})
+	def, _ := newDefinition(nil, dummyFactory, map[string]string{"exists": "abc"})
 
 	t.Run("returns true if tag exists", func(t *testing.T) {
 		assert.True(t, def.HasTag("exists"))
@@ -197,7 +200,7 @@ func TestDefinition_HasTag(t *testing.T) {
 }
 
 func TestDefinition_GetTag(t *testing.T) {
-	def, _ := newDefinition(dummyFactory, map[string]string{"exists": "abc"})
+	def, _ := newDefinition(nil, dummyFactory, map[string]string{"exists": "abc"})
 
 	t.Run("returns tag value if exists", func(t *testing.T) {
 		assert.Equal(t, "abc",  def.GetTag("exists"))
@@ -211,3 +214,87 @@ func TestDefinition_GetTag(t *testing.T) {
 		assert.Equal(t, "",  def.GetTag("not-exists"))
 	})
 }
+
+func TestDefinition_GetTagValues(t *testing.T) {
+	def, _ := newDefinition(nil, dummyFactory, map[string]string{"single": "abc", TagPriority: "1"})
+	_ = def.setTagValues("event", []string{"a;priority=5", "b", "a", "c;priority=-1"})
+
+	t.Run("returns all values", func(t *testing.T) {
+		assert.Equal(t, []string{"abc"}, def.GetTagValues("single"))
+		assert.Equal(t, []string{"a", "b", "c"}, def.GetTagValues("event"))
+		assert.Equal(t, "a", def.GetTag("event"))
+		assert.Nil(t, def.GetTagValues("not-exists"))
+	})
+
+	t.Run("returns if a value exists", func(t *testing.T) {
+		assert.True(t, def.HasTagValue("event", "b"))
+		assert.False(t, def.HasTagValue("event", "d"))
+		assert.False(t, def.HasTagValue("not-exists", ""))
+	})
+
+	t.Run("returns priorities of values", func(t *testing.T) {
+		assert.Equal(t, int16(5), def.GetTagPriority("event", "a"))
+		assert.Equal(t, int16(1), def.GetTagPriority("event", "b"))
+		assert.Equal(t, int16(-1), def.GetTagPriority("event", "c"))
+		assert.Equal(t, int16(5), def.taggedPriority("event", []string{"b", "a"}))
+		assert.Equal(t, int16(-1), def.taggedPriority("event", []string{"c", "d"}))
+		assert.Equal(t, int16(1), def.taggedPriority("event", nil))
+	})
+
+	t.Run("fails on invalid priorities", func(t *testing.T) {
+		assert.Error(t, def.setTagValues("event", []string{"a;priority=99999"}))
+		_, err := newDefinition(nil, dummyFactory, map[string]string{"event": "a;priority=99999"})
+		assert.Error(t, err)
+	})
+}
+
+func TestDefinition_Accessors(t *testing.T) {
+	type Service struct {
+		Dep   string `inject:"dep"`
+		Other int
+	}
+
+	b := newContainerBuilder()
+	b.AddProvider(ProviderFunc(func(b ContainerBuilder) {
+		b.SetValue("provided", 1)
+	}))
+	value := b.SetValue("value #shared #private #priority=3 #custom=a", "v")
+	factory := b.SetFactory("factory #abstract", dummyFactory)
+	inject := b.SetInjectable("inject", &Service{})
+	alias := b.SetAlias("alias", "inject")
+	synthetic := b.SetSynthetic("synthetic", reflect.TypeOf(0))
+	b.GetContainer()
+
+	assert.Equal(t, "value", value.GetKey())
+	assert.Equal(t, TagValue, value.GetKind())
+	assert.Equal(t, map[string]string{TagValue: "", TagShared: "", TagPrivate: "", TagPriority: "3", "custom": "a"},
+		value.GetTags())
+	assert.Equal(t, int16(3), value.GetPriority())
+	assert.True(t, value.IsShared())
+	assert.True(t, value.IsPrivate())
+	assert.False(t, value.IsAbstract())
+	assert.Equal(t, reflect.TypeOf(""), value.GetType())
+	assert.Equal(t, "v", value.GetFactory()(nil))
+	assert.Nil(t, value.GetAliasOf())
+	assert.Nil(t, value.GetInjections())
+	assert.Equal(t, "", value.GetProvider())
+	assert.Contains(t, value.GetSource(), "definition_test.go:")
+
+	assert.True(t, factory.IsAbstract())
+	assert.Nil(t, factory.GetType())
+
+	assert.Equal(t, TagInject, inject.GetKind())
+	assert.Equal(t, reflect.TypeOf(&Service{}), inject.GetType())
+	assert.Equal(t, map[string]string{"Dep": "dep"}, inject.GetInjections())
+
+	assert.Same(t, b.definitions["inject"], alias.GetAliasOf())
+	assert.Equal(t, reflect.TypeOf(&Service{}), alias.GetType())
+	assert.Equal(t, reflect.TypeOf(0), synthetic.GetType())
+	assert.Equal(t, "di.ProviderFunc", b.GetDefinition("provided").GetProvider())
+	assert.Equal(t, "provided", b.GetDefinition("provided").GetKey())
+	assert.Nil(t, b.GetDefinition("missing"))
+
+	tags := value.GetTags()
+	tags["custom"] = "b"
+	assert.Equal(t, "a", value.GetTag("custom"))
+}
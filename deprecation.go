@@ -0,0 +1,127 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TagDeprecated is the reserved tag to declare a service as deprecated. The tag value is used as the migration hint.
+const TagDeprecated = "deprecated"
+
+// Deprecation represents the use of a deprecated service. Chain contains the keys of the services being built when the
+// deprecated service was requested, the last one being the deprecated key itself. On builder reports, ReferencedBy
+// contains the keys of the definitions still referencing the deprecated one.
+type Deprecation struct {
+	Key          string
+	Message      string
+	Chain        []string
+	ReferencedBy []string
+}
+
+// String returns a human-readable warning for the deprecation.
+func (d Deprecation) String() string {
+	msg := fmt.Sprintf("service with key '%s' is deprecated", d.Key)
+	if d.Message != "" {
+		msg += ": " + d.Message
+	}
+
+	if len(d.Chain) > 1 {
+		msg += fmt.Sprintf(" (requested by %s)", strings.Join(d.Chain[:len(d.Chain)-1], " -> "))
+	}
+
+	if len(d.ReferencedBy) > 0 {
+		msg += fmt.Sprintf(" (referenced by %s)", strings.Join(d.ReferencedBy, ", "))
+	}
+
+	return msg
+}
+
+// DeprecationLogger is the hook called by the container when a deprecated service is used.
+type DeprecationLogger func(d Deprecation)
+
+// defaultDeprecationLogger writes the deprecation warnings with the standard logger.
+func defaultDeprecationLogger(d Deprecation) {
+	log.Printf("[di] warning: %s", d)
+}
+
+// deprecations tracks the deprecated keys already notified by a container, so each one is only notified once. It is
+// shared between a container and its unsealed copies.
+type deprecations struct {
+	logger   DeprecationLogger
+	notified map[string]bool
+	lock     sync.Mutex
+}
+
+// notify calls the logger for the deprecated definition on the given key, unless it was already notified.
+func (d *deprecations) notify(def *definition, key string, loading []string) {
+	d.lock.Lock()
+	if d.notified[key] {
+		d.lock.Unlock()
+		return
+	}
+	d.notified[key] = true
+	d.lock.Unlock()
+
+	chain := make([]string, 0, len(loading)+1)
+	chain = append(append(chain, loading...), key)
+
+	d.logger(Deprecation{Key: key, Message: def.GetTag(TagDeprecated), Chain: chain})
+}
+
+// SetDeprecationLogger replaces the hook used by the containers to warn about the use of deprecated services. By
+// default, warnings are written with the standard logger.
+func (c *containerBuilder) SetDeprecationLogger(logger DeprecationLogger) {
+	c.panicIfResolved()
+	c.deprecationLogger = logger
+}
+
+// DeprecationReport returns the deprecated definitions which are still referenced by other definitions, either as
+// aliases, children or injected fields of injectable structs. References made inside factories can't be known
+// beforehand and are only notified when used. The report is sorted by key.
+func (c *containerBuilder) DeprecationReport() []Deprecation {
+	refs := make(map[string]map[string]bool)
+	messages := make(map[string]string)
+	ref := func(target *definition, by string) {
+		if target == nil || !target.HasTag(TagDeprecated) {
+			return
+		}
+		if refs[target.key] == nil {
+			refs[target.key] = make(map[string]bool)
+			messages[target.key] = target.GetTag(TagDeprecated)
+		}
+		refs[target.key][by] = true
+	}
+
+	for key, def := range c.definitions {
+		ref(def.AliasOf, key)
+		ref(def.Parent, key)
+		if def.Injection != nil {
+			for _, k := range def.Injection.Fields {
+				ref(c.definitions[k], key)
+			}
+		}
+	}
+
+	report := make([]Deprecation, 0, len(refs))
+	for k, set := range refs {
+		by := make([]string, 0, len(set))
+		for b := range set {
+			by = append(by, b)
+		}
+		sort.Strings(by)
+		report = append(report, Deprecation{Key: k, Message: messages[k], ReferencedBy: by})
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Key < report[j].Key
+	})
+
+	return report
+}
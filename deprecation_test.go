@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestContainer_GetDeprecated(t *testing.T) {
+	newBuilder := func(logged *[]Deprecation) *containerBuilder {
+		b := newContainerBuilder()
+		b.SetDeprecationLogger(func(d Deprecation) {
+			*logged = append(*logged, d)
+		})
+		b.SetValue("old #deprecated=use new instead #tag", 1)
+		b.SetFactory("user", func(c Container) interface{} { return c.Get("old") })
+		b.SetFactory("parent", func(c Container) interface{} { return c.Get("user") })
+
+		return b
+	}
+
+	t.Run("warns once per key when retrieved", func(t *testing.T) {
+		logged := make([]Deprecation, 0)
+		c := newBuilder(&logged).GetContainer()
+
+		c.Get("old")
+		c.Get("old")
+		c.GetTaggedBy("tag")
+
+		assert.Equal(t, []Deprecation{{Key: "old", Message: "use new instead", Chain: []string{"old"}}}, logged)
+	})
+
+	t.Run("warns with the requesting chain when injected", func(t *testing.T) {
+		logged := make([]Deprecation, 0)
+		c := newBuilder(&logged).GetContainer()
+
+		c.Get("parent")
+		c.Get("user")
+
+		assert.Equal(t, []Deprecation{{Key: "old", Message: "use new instead", Chain: []string{"parent", "user", "old"}}}, logged)
+		assert.Equal(t, "service with key 'old' is deprecated: use new instead (requested by parent -> user)", logged[0].String())
+	})
+
+	t.Run("warns again on a new container", func(t *testing.T) {
+		logged := make([]Deprecation, 0)
+		b := newBuilder(&logged)
+
+		b.GetContainer().Get("old")
+		b.GetContainer().Get("old")
+
+		assert.Len(t, logged, 2)
+	})
+
+	t.Run("panics setting logger if resolved", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.GetContainer()
+
+		assert.PanicsWithValue(t, "container is resolved and new items can not be set", func() {
+			b.SetDeprecationLogger(defaultDeprecationLogger)
+		})
+	})
+}
+
+func TestContainerBuilder_DeprecationReport(t *testing.T) {
+	type Injectable struct {
+		F1 int `inject:"old"`
+		F2 int `inject:"old"`
+		F3 int `inject:"new"`
+	}
+
+	b := NewContainerBuilder()
+	b.SetValue("new", 1)
+	b.SetValue("old #deprecated=use new", 1)
+	b.SetValue("unused #deprecated", 1)
+	b.SetFactory("base #abstract #deprecated", dummyFactory)
+	b.SetAlias("alias", "old")
+	b.SetChild("child", "base")
+	b.SetInjectable("injectable", Injectable{})
+
+	report := b.DeprecationReport()
+
+	assert.False(t, b.GetDefinition("child").HasTag(TagDeprecated))
+	assert.Equal(t, []Deprecation{
+		{Key: "base", Message: "", ReferencedBy: []string{"child"}},
+		{Key: "old", Message: "use new", ReferencedBy: []string{"alias", "injectable"}},
+	}, report)
+	assert.Equal(t, "service with key 'old' is deprecated: use new (referenced by alias, injectable)", report[1].String())
+
+	t.Run("reports overwritten definitions by key", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("old #deprecated=use new", 1)
+		b.SetAlias("alias", "old")
+		b.SetValue("old", 2)
+
+		assert.Equal(t, []Deprecation{
+			{Key: "old", Message: "use new", ReferencedBy: []string{"alias"}},
+		}, b.DeprecationReport())
+	})
+}
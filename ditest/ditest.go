@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package ditest provides helpers to replace the services of a container with fakes in tests. Replaced services are
+// restored automatically when the test, and all its subtests, complete.
+package ditest
+
+import (
+	"sort"
+
+	"github.com/golossus/di"
+)
+
+// Cleaner registers functions to be called when a test completes, as testing.T and testing.B do since Go 1.14. On
+// previous versions, any type running the registered functions from a deferred call can be used instead.
+type Cleaner interface {
+	Cleanup(f func())
+}
+
+// Overrider is a container whose services can be overridden, as the ones returned by di.ContainerBuilder.
+type Overrider interface {
+	di.Container
+	Override(key string, value interface{}) (restore func())
+}
+
+// Override replaces the service on the given key of an already built container with the given value. The shared
+// services depending on it are built again with the new value, and the previous service is restored on test cleanup.
+func Override(t Cleaner, c Overrider, key string, value interface{}) {
+	helper(t)
+	t.Cleanup(c.Override(key, value))
+}
+
+// WithOverrides returns the container of the given builder with the services on the keys of the given map replaced
+// by their values, until the test completes.
+func WithOverrides(t Cleaner, b di.ContainerBuilder, overrides map[string]interface{}) Overrider {
+	helper(t)
+
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	c := b.GetContainer()
+	for _, k := range keys {
+		Override(t, c, k, overrides[k])
+	}
+
+	return c
+}
+
+// helper marks the caller as a test helper if the given Cleaner is a test.
+func helper(t Cleaner) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+}
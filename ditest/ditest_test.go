@@ -0,0 +1,61 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ditest
+
+import (
+	"testing"
+
+	"github.com/golossus/di"
+	"github.com/stretchr/testify/assert"
+)
+
+type mailer struct {
+	Sender string `inject:"sender"`
+}
+
+// cleanups is a Cleaner running the registered functions in reverse order, as tests do, for Go versions before 1.14.
+type cleanups []func()
+
+func (c *cleanups) Cleanup(f func()) {
+	*c = append(*c, f)
+}
+
+func (c *cleanups) run() {
+	for i := len(*c) - 1; i >= 0; i-- {
+		(*c)[i]()
+	}
+}
+
+func newBuilder() di.ContainerBuilder {
+	b := di.NewContainerBuilder()
+	b.SetValue("sender #private", "smtp")
+	b.SetValue("from", "from@email.com")
+	b.SetInjectable("mailer #shared", &mailer{})
+
+	return b
+}
+
+func TestOverride(t *testing.T) {
+	c := newBuilder().GetContainer()
+	assert.Equal(t, "smtp", c.Get("mailer").(*mailer).Sender)
+
+	cl := &cleanups{}
+	Override(cl, c, "sender", "fake")
+	assert.Equal(t, "fake", c.Get("mailer").(*mailer).Sender)
+
+	cl.run()
+	assert.Equal(t, "smtp", c.Get("mailer").(*mailer).Sender)
+}
+
+func TestWithOverrides(t *testing.T) {
+	cl := &cleanups{}
+	c := WithOverrides(cl, newBuilder(), map[string]interface{}{"sender": "fake", "from": "fake@email.com"})
+	assert.Equal(t, "fake", c.Get("mailer").(*mailer).Sender)
+	assert.Equal(t, "fake@email.com", c.Get("from"))
+
+	cl.run()
+	assert.Equal(t, "smtp", c.Get("mailer").(*mailer).Sender)
+	assert.Equal(t, "from@email.com", c.Get("from"))
+}
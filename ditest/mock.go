@@ -0,0 +1,170 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ditest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golossus/di"
+)
+
+// Call is a method call recorded by a Recorder.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Recorder records the method calls made to a stub. It is safe for concurrent use.
+type Recorder struct {
+	calls []Call
+	lock  sync.Mutex
+}
+
+// Record records a call to the given method with the given arguments.
+func (r *Recorder) Record(method string, args ...interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.calls = append(r.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns the recorded calls, optionally only the ones to the given methods, in call order.
+func (r *Recorder) Calls(methods ...string) []Call {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	calls := make([]Call, 0, len(r.calls))
+	for _, c := range r.calls {
+		if len(methods) == 0 || inSlice(c.Method, methods) {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+// inSlice returns if the given string is in the slice.
+func inSlice(s string, slice []string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// StubFactory returns a stub implementing an interface which records its calls on the given Recorder.
+type StubFactory func(r *Recorder) interface{}
+
+// stubs is the registry of stub factories indexed by interface type.
+var stubs = struct {
+	factories map[reflect.Type]StubFactory
+	lock      sync.RWMutex
+}{factories: make(map[reflect.Type]StubFactory)}
+
+// RegisterStub registers the factory of the stubs used by AutoMock for the given interface type. Stubs generated by
+// GenerateStubs are registered on init. It panics if the given type is not an interface.
+func RegisterStub(iface reflect.Type, factory StubFactory) {
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("type %s is not an interface and stubs can't be registered", iface))
+	}
+
+	stubs.lock.Lock()
+	defer stubs.lock.Unlock()
+
+	stubs.factories[iface] = factory
+}
+
+// stubFactory returns the factory of stubs for the given interface type, if registered. Empty interfaces are
+// implemented by the Recorder itself.
+func stubFactory(iface reflect.Type) (StubFactory, bool) {
+	if iface.NumMethod() == 0 {
+		return func(r *Recorder) interface{} { return r }, true
+	}
+
+	stubs.lock.RLock()
+	defer stubs.lock.RUnlock()
+
+	f, ok := stubs.factories[iface]
+	return f, ok
+}
+
+// MockReport is the result of AutoMock. Mocked are the sorted keys defined with stubs, and Unsupported the ones left
+// undefined because no stub is registered for their interface type.
+type MockReport struct {
+	Mocked      []string
+	Unsupported []string
+	recorders   map[string]*Recorder
+}
+
+// Recorder returns the recorder of the stub defined on the given key, or nil if the key was not mocked.
+func (r *MockReport) Recorder(key string) *Recorder {
+	return r.recorders[key]
+}
+
+// String returns the mocked and unsupported keys.
+func (r *MockReport) String() string {
+	return fmt.Sprintf("mocked: [%s], unsupported: [%s]", strings.Join(r.Mocked, ", "),
+		strings.Join(r.Unsupported, ", "))
+}
+
+// AutoMock defines stubs for the missing dependencies of the injectables of the given builder which are injected on
+// interface fields, so a service can be tested without defining the whole graph. Each stub records its calls and
+// returns zero values. Stubs are created by the factories registered for each interface type, see RegisterStub and
+// GenerateStubs. Definitions added later, e.g. by providers when the container is resolved, are not considered, so it
+// must be called after defining the services to test.
+func AutoMock(b di.ContainerBuilder) *MockReport {
+	types := make(map[string]reflect.Type)
+	for _, k := range b.GetTaggedKeys(di.TagInject, nil) {
+		def := b.GetDefinition(k)
+		fields := def.GetInjections()
+		if fields == nil {
+			continue
+		}
+
+		typ := def.GetType()
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		for name, dep := range fields {
+			f, _ := typ.FieldByName(name)
+			t := f.Type
+			if t.Kind() != reflect.Interface || strings.HasPrefix(dep, "locator:") || b.HasDefinition(dep) {
+				continue
+			}
+			types[dep] = t
+		}
+	}
+
+	keys := make([]string, 0, len(types))
+	for k := range types {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	report := &MockReport{
+		Mocked:      make([]string, 0),
+		Unsupported: make([]string, 0),
+		recorders:   make(map[string]*Recorder),
+	}
+	for _, k := range keys {
+		factory, ok := stubFactory(types[k])
+		if !ok {
+			report.Unsupported = append(report.Unsupported, k)
+			continue
+		}
+
+		r := &Recorder{}
+		b.SetValue(k, factory(r))
+		report.Mocked = append(report.Mocked, k)
+		report.recorders[k] = r
+	}
+
+	return report
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ditest
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/golossus/di"
+	"github.com/stretchr/testify/assert"
+)
+
+// Sender is an interface stubbed in stubs_generated_test.go.
+type Sender interface {
+	Send(to string, body ...string) (int, error)
+	OnSent(func(to string) error)
+	Close()
+}
+
+type Notifier struct {
+	Sender Sender      `inject:"sender"`
+	Clock  interface{} `inject:"clock"`
+	Closer interface {
+		Close() error
+	} `inject:"closer"`
+	From string `inject:"from"`
+}
+
+func TestRecorder(t *testing.T) {
+	r := &Recorder{}
+	r.Record("Send", "a", 1)
+	r.Record("Close")
+
+	assert.Equal(t, []Call{{Method: "Send", Args: []interface{}{"a", 1}}, {Method: "Close"}}, r.Calls())
+	assert.Equal(t, []Call{{Method: "Close"}}, r.Calls("Close"))
+}
+
+func TestRegisterStub(t *testing.T) {
+	assert.PanicsWithValue(t, "type string is not an interface and stubs can't be registered", func() {
+		RegisterStub(reflect.TypeOf(""), nil)
+	})
+}
+
+func TestAutoMock(t *testing.T) {
+	b := di.NewContainerBuilder()
+	b.SetValue("from", "me")
+	b.SetInjectable("notifier", &Notifier{})
+
+	report := AutoMock(b)
+
+	assert.Equal(t, []string{"clock", "sender"}, report.Mocked)
+	assert.Equal(t, []string{"closer"}, report.Unsupported)
+	assert.Equal(t, "mocked: [clock, sender], unsupported: [closer]", report.String())
+	assert.Nil(t, report.Recorder("closer"))
+
+	b.SetValue("closer", ioutil.NopCloser(nil))
+	n := b.GetContainer().Get("notifier").(*Notifier)
+	count, err := n.Sender.Send("you", "hello", "bye")
+	n.Sender.Close()
+
+	assert.Equal(t, 0, count)
+	assert.Nil(t, err)
+	assert.Same(t, report.Recorder("clock"), n.Clock)
+	assert.Equal(t, []Call{
+		{Method: "Send", Args: []interface{}{"you", []string{"hello", "bye"}}},
+		{Method: "Close"},
+	}, report.Recorder("sender").Calls())
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ditest
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ditestPkgPath is the import path of this package, referenced by the generated stubs.
+const ditestPkgPath = "github.com/golossus/di/ditest"
+
+// StubOptions configures the code generated by GenerateStubs. Package is the name of the package of the generated file
+// and PkgPath its import path, used to reference its own types without importing it.
+type StubOptions struct {
+	Package string
+	PkgPath string
+}
+
+// stubGenerator holds the state of the stubs being generated.
+type stubGenerator struct {
+	opts    StubOptions
+	imports map[string]string
+}
+
+// GenerateStubs returns the source code of recording stubs for the given interface types, registered on init to be
+// used by AutoMock. Stubs are named after the interface with the "Stub" suffix, embed a *Recorder and return zero values.
+// It is meant to be run by a program invoked by go generate, e.g. to write a stubs_test.go file:
+//
+//	src, err := ditest.GenerateStubs(ditest.StubOptions{Package: "app", PkgPath: "example.com/app"},
+//		reflect.TypeOf((*app.Sender)(nil)).Elem())
+func GenerateStubs(opts StubOptions, ifaces ...reflect.Type) ([]byte, error) {
+	g := &stubGenerator{opts: opts, imports: map[string]string{"reflect": "reflect", ditestPkgPath: "ditest"}}
+
+	var body bytes.Buffer
+	registrations := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		name, err := g.stub(&body, iface)
+		if err != nil {
+			return nil, err
+		}
+
+		t, _ := g.typeExpr(iface)
+		registrations = append(registrations, fmt.Sprintf("%s(reflect.TypeOf((*%s)(nil)).Elem(), func(r *%s) interface{} {\n"+
+			"return &%s{Recorder: r}\n})\n", g.qualify(ditestPkgPath, "RegisterStub"), t,
+			g.qualify(ditestPkgPath, "Recorder"), name))
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by github.com/golossus/di/ditest. DO NOT EDIT.\n\npackage %s\n\n", opts.Package)
+
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		if p != opts.PkgPath {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	sort.SliceStable(paths, func(i, j int) bool {
+		return !strings.Contains(paths[i], ".") && strings.Contains(paths[j], ".")
+	})
+	fmt.Fprintf(&src, "import (\n")
+	for i, p := range paths {
+		if i > 0 && strings.Contains(p, ".") && !strings.Contains(paths[i-1], ".") {
+			fmt.Fprintf(&src, "\n")
+		}
+		if name := g.imports[p]; name != path.Base(p) {
+			fmt.Fprintf(&src, "%s %q\n", name, p)
+			continue
+		}
+		fmt.Fprintf(&src, "%q\n", p)
+	}
+	fmt.Fprintf(&src, ")\n\n")
+
+	fmt.Fprintf(&src, "func init() {\n%s}\n\n", strings.Join(registrations, ""))
+	src.Write(body.Bytes())
+
+	return format.Source(src.Bytes())
+}
+
+// stub writes the stub type of the given interface and its methods, and returns the stub type name.
+func (g *stubGenerator) stub(w *bytes.Buffer, iface reflect.Type) (string, error) {
+	if iface.Kind() != reflect.Interface || iface.Name() == "" {
+		return "", fmt.Errorf("type %s is not a named interface and stubs can't be generated", iface)
+	}
+
+	t, err := g.typeExpr(iface)
+	if err != nil {
+		return "", err
+	}
+
+	name := iface.Name() + "Stub"
+	fmt.Fprintf(w, "// %s is a stub of %s which records its calls and returns zero values.\n", name, t)
+	fmt.Fprintf(w, "type %s struct {\n*%s\n}\n\n", name, g.qualify(ditestPkgPath, "Recorder"))
+
+	for i := 0; i < iface.NumMethod(); i++ {
+		m := iface.Method(i)
+		if m.PkgPath != "" {
+			return "", fmt.Errorf("unexported method %s of %s can't be stubbed", m.Name, iface)
+		}
+
+		params := make([]string, 0, m.Type.NumIn())
+		args := make([]string, 0, m.Type.NumIn())
+		for j := 0; j < m.Type.NumIn(); j++ {
+			in := m.Type.In(j)
+			variadic := m.Type.IsVariadic() && j == m.Type.NumIn()-1
+			if variadic {
+				in = in.Elem()
+			}
+			pt, err := g.typeExpr(in)
+			if err != nil {
+				return "", fmt.Errorf("method %s of %s can't be stubbed: %s", m.Name, iface, err)
+			}
+			if variadic {
+				pt = "..." + pt
+			}
+			params = append(params, fmt.Sprintf("a%d %s", j, pt))
+			args = append(args, fmt.Sprintf("a%d", j))
+		}
+
+		results := make([]string, 0, m.Type.NumOut())
+		for j := 0; j < m.Type.NumOut(); j++ {
+			rt, err := g.typeExpr(m.Type.Out(j))
+			if err != nil {
+				return "", fmt.Errorf("method %s of %s can't be stubbed: %s", m.Name, iface, err)
+			}
+			results = append(results, fmt.Sprintf("r%d %s", j, rt))
+		}
+
+		record := strconv.Quote(m.Name)
+		if len(args) > 0 {
+			record += ", " + strings.Join(args, ", ")
+		}
+		fmt.Fprintf(w, "// %s records the call and returns zero values.\n", m.Name)
+		fmt.Fprintf(w, "func (s *%s) %s(%s) (%s) {\n", name, m.Name, strings.Join(params, ", "), strings.Join(results, ", "))
+		fmt.Fprintf(w, "s.Recorder.Record(%s)\nreturn\n}\n\n", record)
+	}
+
+	return name, nil
+}
+
+// qualify returns the given name qualified by the package with the given import path, importing it if needed.
+func (g *stubGenerator) qualify(pkgPath, name string) string {
+	if pkgPath == g.opts.PkgPath {
+		return name
+	}
+
+	q, ok := g.imports[pkgPath]
+	if !ok {
+		base := path.Base(pkgPath)
+		q = base
+		taken := func(n string) bool {
+			for _, v := range g.imports {
+				if v == n {
+					return true
+				}
+			}
+			return n == g.opts.Package
+		}
+		for i := 2; taken(q); i++ {
+			q = base + strconv.Itoa(i)
+		}
+		g.imports[pkgPath] = q
+	}
+
+	return q + "." + name
+}
+
+// typeExpr returns the Go expression of the given type, importing its packages.
+func (g *stubGenerator) typeExpr(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name(), nil
+		}
+		if t.PkgPath() != g.opts.PkgPath && !isExported(t.Name()) {
+			return "", fmt.Errorf("unexported type %s can not be referenced from package %s", t, g.opts.PkgPath)
+		}
+		return g.qualify(t.PkgPath(), t.Name()), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Chan:
+		elem, err := g.typeExpr(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		switch t.Kind() {
+		case reflect.Ptr:
+			return "*" + elem, nil
+		case reflect.Slice:
+			return "[]" + elem, nil
+		case reflect.Array:
+			return fmt.Sprintf("[%d]%s", t.Len(), elem), nil
+		}
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			return "<-chan " + elem, nil
+		case reflect.SendDir:
+			return "chan<- " + elem, nil
+		}
+		return "chan " + elem, nil
+	case reflect.Map:
+		key, err := g.typeExpr(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := g.typeExpr(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("map[%s]%s", key, elem), nil
+	case reflect.Func:
+		return g.funcExpr(t)
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", nil
+		}
+	}
+
+	return "", fmt.Errorf("unnamed type %s is not supported", t)
+}
+
+// funcExpr returns the Go expression of the given unnamed func type.
+func (g *stubGenerator) funcExpr(t reflect.Type) (string, error) {
+	in := make([]string, 0, t.NumIn())
+	for i := 0; i < t.NumIn(); i++ {
+		p := t.In(i)
+		prefix := ""
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			p, prefix = p.Elem(), "..."
+		}
+		e, err := g.typeExpr(p)
+		if err != nil {
+			return "", err
+		}
+		in = append(in, prefix+e)
+	}
+
+	out := make([]string, 0, t.NumOut())
+	for i := 0; i < t.NumOut(); i++ {
+		e, err := g.typeExpr(t.Out(i))
+		if err != nil {
+			return "", err
+		}
+		out = append(out, e)
+	}
+
+	return fmt.Sprintf("func(%s) (%s)", strings.Join(in, ", "), strings.Join(out, ", ")), nil
+}
+
+// isExported returns if the given identifier is exported.
+func isExported(name string) bool {
+	return name != "" && strings.ToUpper(name[:1]) == name[:1]
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ditest
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the generated stubs")
+
+func TestGenerateStubs(t *testing.T) {
+	t.Run("generates stubs registered on init", func(t *testing.T) {
+		src, err := GenerateStubs(StubOptions{Package: "ditest", PkgPath: ditestPkgPath},
+			reflect.TypeOf((*Sender)(nil)).Elem())
+		assert.Nil(t, err)
+
+		if *update {
+			assert.Nil(t, ioutil.WriteFile("stubs_generated_test.go", src, 0644))
+		}
+
+		expected, err := ioutil.ReadFile("stubs_generated_test.go")
+		assert.Nil(t, err)
+		assert.Equal(t, string(expected), string(src))
+	})
+
+	t.Run("imports the packages of referenced types", func(t *testing.T) {
+		src, err := GenerateStubs(StubOptions{Package: "app", PkgPath: "example.com/app"},
+			reflect.TypeOf((*Sender)(nil)).Elem())
+
+		assert.Nil(t, err)
+		assert.Contains(t, string(src), "import (\n\t\"reflect\"\n\n\t\"github.com/golossus/di/ditest\"\n)")
+		assert.Contains(t, string(src), "ditest.RegisterStub(reflect.TypeOf((*ditest.Sender)(nil)).Elem(), "+
+			"func(r *ditest.Recorder) interface{} {")
+		assert.Contains(t, string(src), "type SenderStub struct {\n\t*ditest.Recorder\n}")
+	})
+
+	t.Run("fails if type is not a named interface", func(t *testing.T) {
+		_, err := GenerateStubs(StubOptions{Package: "app"}, reflect.TypeOf(""))
+
+		assert.EqualError(t, err, "type string is not a named interface and stubs can't be generated")
+	})
+}
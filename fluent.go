@@ -0,0 +1,107 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefinitionBuilder is the Definition returned by the builder setters, which can be configured further with chained
+// calls until the builder is resolved:
+//
+//	b.SetFactory("email.mailer", newMailer).Shared().Tag("listener", "user.created").Priority(10).Alias("mailer")
+//
+// Every call updates the tags of the definition and the flags derived from them, as if the tags were given when the
+// definition was set, so both are kept consistent. Calls panic once the builder is resolved, if a tag is not valid or
+// if the definition has been overwritten by another one on the same key, as the changes would be lost.
+type DefinitionBuilder interface {
+	Definition
+	Shared() DefinitionBuilder
+	Private() DefinitionBuilder
+	Priority(priority int16) DefinitionBuilder
+	Tag(tag string, values ...string) DefinitionBuilder
+	Alias(keys ...string) DefinitionBuilder
+}
+
+// definitionBuilder implements DefinitionBuilder for a definition of a containerBuilder.
+type definitionBuilder struct {
+	*definition
+	builder *containerBuilder
+}
+
+// configure returns the DefinitionBuilder of the given definition.
+func (c *containerBuilder) configure(d *definition) DefinitionBuilder {
+	return &definitionBuilder{definition: d, builder: c}
+}
+
+// panicIfOverwritten panics if the definition is no longer the one set on its key of the builder.
+func (d *definitionBuilder) panicIfOverwritten() {
+	if d.builder.definitions[d.key] != d.definition {
+		panic(fmt.Sprintf("definition with key '%s' has been overwritten and can't be configured", d.key))
+	}
+}
+
+// Shared sets the TagShared tag, so the service is built once and shared by all retrievals.
+func (d *definitionBuilder) Shared() DefinitionBuilder {
+	return d.Tag(TagShared)
+}
+
+// Private sets the TagPrivate tag, so the service can only be retrieved as a dependency of other services.
+func (d *definitionBuilder) Private() DefinitionBuilder {
+	return d.Tag(TagPrivate)
+}
+
+// Priority sets the TagPriority tag, used to sort the services retrieved by tag.
+func (d *definitionBuilder) Priority(priority int16) DefinitionBuilder {
+	return d.Tag(TagPriority, strconv.Itoa(int(priority)))
+}
+
+// Tag sets the given values of a tag, replacing the current ones, or an empty value if none is given. Values accept
+// the priority attribute, as in keys. Kind tags, TagOverride and injection override tags can only be set when the
+// definition is set, so they make it panic.
+func (d *definitionBuilder) Tag(tag string, values ...string) DefinitionBuilder {
+	d.builder.panicIfResolved()
+	d.panicIfOverwritten()
+
+	if tag == "" || inStrings(tag, kindTags) || tag == TagOverride || strings.HasPrefix(tag, injectOverridePrefix) {
+		panic(fmt.Sprintf("tag '%s' can't be set on the definition with key '%s' once it is set", tag, d.key))
+	}
+	if len(values) == 0 {
+		values = []string{""}
+	}
+
+	next := &definition{
+		schemas:       d.schemas,
+		Tags:          d.GetTags(),
+		TagValues:     make(map[string][]string),
+		TagPriorities: make(map[string]map[string]int16),
+	}
+	err := next.setTagValues(tag, values)
+	if err == nil {
+		err = next.parseTags()
+	}
+	if err != nil {
+		panic(fmt.Sprintf("%s for key '%s'", err, d.key))
+	}
+
+	_ = d.setTagValues(tag, values)
+	_ = d.parseTags()
+
+	return d
+}
+
+// Alias sets aliases of the definition on the given keys, see ContainerBuilder.SetAlias.
+func (d *definitionBuilder) Alias(keys ...string) DefinitionBuilder {
+	d.builder.panicIfResolved()
+	d.panicIfOverwritten()
+
+	for _, k := range keys {
+		d.builder.SetAlias(k, d.key)
+	}
+
+	return d
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefinitionBuilder(t *testing.T) {
+	t.Run("configures tags and flags consistently", func(t *testing.T) {
+		b := newContainerBuilder()
+		b.SetValue("low #listener", "low")
+		d := b.SetFactory("mailer", func(c Container) interface{} {
+			return &struct{}{}
+		}).Shared().Private().Tag("listener", "a", "b;priority=20").Priority(10).Alias("default.mailer")
+
+		assert.True(t, d.IsShared())
+		assert.True(t, d.IsPrivate())
+		assert.Equal(t, int16(10), d.GetPriority())
+		assert.Equal(t, map[string]string{TagFactory: "", TagShared: "", TagPrivate: "", TagPriority: "10", "listener": "a"},
+			d.GetTags())
+		assert.Equal(t, []string{"a", "b"}, d.GetTagValues("listener"))
+		assert.Equal(t, int16(20), d.GetTagPriority("listener", "b"))
+		assert.Same(t, b.definitions["mailer"], b.GetDefinition("default.mailer").GetAliasOf())
+		assert.Contains(t, b.GetDefinition("default.mailer").GetSource(), "fluent_test.go:")
+
+		c := b.GetContainer()
+		assert.Panics(t, func() {
+			c.Get("mailer")
+		})
+		assert.Same(t, c.Get("default.mailer"), c.Get("default.mailer"))
+		assert.Equal(t, []string{"mailer", "low"}, b.GetTaggedKeys("listener", nil))
+		assert.Equal(t, []string{"mailer"}, b.GetTaggedKeys("listener", []string{"b"}))
+	})
+
+	t.Run("replaces tag values", func(t *testing.T) {
+		b := NewContainerBuilder()
+		d := b.SetValue("k #shared #priority=3 #event=a;priority=5 #event=b", 1)
+
+		d.Tag(TagShared, "false").Tag(TagPriority, "-1").Tag("event", "c").Tag("flag")
+
+		assert.False(t, d.IsShared())
+		assert.Equal(t, int16(-1), d.GetPriority())
+		assert.Equal(t, []string{"c"}, d.GetTagValues("event"))
+		assert.Equal(t, int16(-1), d.GetTagPriority("event", "a"))
+		assert.Equal(t, "", d.GetTag("flag", "missing"))
+	})
+
+	t.Run("panics on invalid tags without changing the definition", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.RegisterTag(TagSchema{Name: "test.retries", Type: TagTypeInt})
+		d := b.SetValue("k #priority=3", 1)
+
+		for tag, err := range map[string]string{
+			TagPriority:    "priority tag value 'x' is not a valid number for key 'k'",
+			TagShared:      "shared tag value 'x' is not a valid boolean for key 'k'",
+			"event":        "priority of tag value 'x;priority=99999' is not a valid number for key 'k'",
+			"test.retries": "test.retries tag value 'x' is not a valid number for key 'k'",
+			TagFactory:     "tag 'factory' can't be set on the definition with key 'k' once it is set",
+			TagOverride:    "tag 'override' can't be set on the definition with key 'k' once it is set",
+			"inject.Field": "tag 'inject.Field' can't be set on the definition with key 'k' once it is set",
+			"":             "tag '' can't be set on the definition with key 'k' once it is set",
+		} {
+			value := "x"
+			if tag == "event" {
+				value = "x;priority=99999"
+			}
+			assert.PanicsWithValue(t, err, func() {
+				d.Tag(tag, value)
+			}, tag)
+		}
+
+		assert.Equal(t, map[string]string{TagValue: "", TagPriority: "3"}, d.GetTags())
+		assert.Equal(t, int16(3), d.GetPriority())
+		assert.False(t, d.IsShared())
+	})
+
+	t.Run("panics once the builder is resolved", func(t *testing.T) {
+		var provided DefinitionBuilder
+		b := NewContainerBuilder()
+		b.AddProvider(ProviderFunc(func(b ContainerBuilder) {
+			provided = b.SetValue("provided", 1)
+		}))
+		d := b.SetValue("k", 1)
+		b.GetContainer()
+
+		for _, def := range []DefinitionBuilder{d, provided} {
+			assert.PanicsWithValue(t, "container is resolved and new items can not be set", func() {
+				def.Shared()
+			})
+			assert.PanicsWithValue(t, "container is resolved and new items can not be set", func() {
+				def.Alias("alias")
+			})
+		}
+		assert.False(t, b.GetDefinition("k").IsShared())
+	})
+
+	t.Run("panics once the definition is overwritten", func(t *testing.T) {
+		b := NewContainerBuilder()
+		d := b.SetValue("k", 1)
+		b.SetValue("k", 2)
+
+		msg := "definition with key 'k' has been overwritten and can't be configured"
+		assert.PanicsWithValue(t, msg, func() {
+			d.Shared()
+		})
+		assert.PanicsWithValue(t, msg, func() {
+			d.Alias("alias")
+		})
+		assert.False(t, d.IsShared())
+		assert.False(t, b.GetDefinition("k").IsShared())
+		assert.False(t, b.HasDefinition("alias"))
+	})
+}
@@ -0,0 +1,813 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"math"
+	"path"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// diPkgPath is the import path of this package, referenced by the generated containers.
+const diPkgPath = "github.com/golossus/di"
+
+// GenerateOptions configures the code generated by Generate. Package is the name of the package of the generated file,
+// PkgPath its import path, used to reference its own types and functions without importing it, and Type the name of
+// the generated container type.
+type GenerateOptions struct {
+	Package string
+	PkgPath string
+	Type    string
+}
+
+// generatedNames are the names of the methods and fields of the generated containers which can't be used by the
+// methods of the services.
+var generatedNames = []string{
+	"Get", "GetTaggedBy", "Provide", "get", "taggedBy", "synthetic", "unsealed", "enter", "factories", "synthetics",
+	"loading",
+}
+
+// generator holds the state of a container being generated.
+type generator struct {
+	opts      GenerateOptions
+	builder   *containerBuilder
+	defs      map[string]*definition
+	keys      []string
+	imports   map[string]string
+	names     map[string]string
+	used      map[string]bool
+	types     map[string]string
+	factories []string
+	shared    bool
+	synthetic bool
+}
+
+// Generate resolves the given builder and returns the source code of a container with a method per service, built
+// without reflection. Injectables are wired with struct literals, values of basic types are written as literals, named
+// functions used as factories are called directly and shared services are stored on fields of the container. Factories
+// defined as closures and the rest of values are retrieved from the builder passed to the generated constructor, which
+// must be configured as the given one. The generated container implements Container, so existing factories still work.
+// As in the reflective container, factories receive an unsealed view of the container which can retrieve the private
+// services, while the Get method of the container itself panics for them. Services are built by the view, which keeps
+// the keys being built to panic on circular references, and shared services are only stored once built successfully.
+//
+// It is meant to be run by a program invoked by go generate, which writes the returned code to a file:
+//
+//	//go:generate go run ./gen
+//	src, err := di.Generate(app.NewBuilder(), di.GenerateOptions{Package: "app", PkgPath: "example.com/app", Type: "AppContainer"})
+//
+// Only builders returned by NewContainerBuilder are supported. Locators are not supported, and circular references
+// between injectables or aliases are reported as errors because the generated singletons would lock each other.
+func Generate(b ContainerBuilder, opts GenerateOptions) ([]byte, error) {
+	builder, ok := b.(*containerBuilder)
+	if !ok {
+		return nil, fmt.Errorf("builder of type %T is not supported", b)
+	}
+	builder.GetContainer()
+
+	g := &generator{
+		opts:    opts,
+		builder: builder,
+		defs:    builder.definitions,
+		imports: map[string]string{"fmt": "fmt", diPkgPath: "di"},
+		names:   make(map[string]string),
+		used:    make(map[string]bool),
+		types:   make(map[string]string),
+	}
+	if opts.PkgPath == diPkgPath {
+		delete(g.imports, diPkgPath)
+	}
+	for _, n := range generatedNames {
+		g.used[n] = true
+	}
+	g.used[opts.Type] = true
+
+	for k, d := range g.defs {
+		if !d.Abstract {
+			g.keys = append(g.keys, k)
+		}
+	}
+	sort.Strings(g.keys)
+
+	if err := g.checkCircular(); err != nil {
+		return nil, err
+	}
+
+	for _, k := range g.keys {
+		g.names[k] = g.name(k)
+	}
+
+	var body, view bytes.Buffer
+	for _, k := range g.keys {
+		if err := g.build(&view, k); err != nil {
+			return nil, err
+		}
+		g.method(&body, k)
+	}
+	g.get(&body)
+	g.getTaggedBy(&body)
+	g.unsealed(&body)
+	body.Write(view.Bytes())
+	if g.synthetic {
+		g.provide(&body)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by github.com/golossus/di. DO NOT EDIT.\n\npackage %s\n\n", opts.Package)
+	g.header(&src)
+	src.Write(body.Bytes())
+
+	return format.Source(src.Bytes())
+}
+
+// checkCircular returns an error if there are circular references between injectables and aliases.
+func (g *generator) checkCircular() error {
+	visited := make(map[string]int)
+	var visit func(k string, chain []string) error
+	visit = func(k string, chain []string) error {
+		chain = append(chain, k)
+		switch visited[k] {
+		case 1:
+			return fmt.Errorf("circular reference found while generating service '%s' at %s", chain[0],
+				strings.Join(chain, " -> "))
+		case 2:
+			return nil
+		}
+
+		visited[k] = 1
+		for _, dep := range g.dependencies(k) {
+			if err := visit(dep, chain); err != nil {
+				return err
+			}
+		}
+		visited[k] = 2
+
+		return nil
+	}
+
+	for _, k := range g.keys {
+		if err := visit(k, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dependencies returns the keys of the services called directly by the generated method of the given key.
+func (g *generator) dependencies(key string) []string {
+	d, ok := g.defs[key]
+	if !ok {
+		return nil
+	}
+
+	deps := make([]string, 0)
+	if d.Kind == TagAlias {
+		if target := g.keyOf(d.AliasOf); target != "" {
+			deps = append(deps, target)
+		}
+	}
+	if d.Kind == TagInject && d.Injection != nil {
+		for _, k := range d.Injection.Fields {
+			if _, ok := g.defs[k]; ok {
+				deps = append(deps, k)
+			}
+		}
+		sort.Strings(deps)
+	}
+
+	return deps
+}
+
+// keyOf returns the key of the given definition.
+func (g *generator) keyOf(def *definition) string {
+	for k, d := range g.defs {
+		if d == def {
+			return k
+		}
+	}
+
+	return ""
+}
+
+// name returns a unique method name for the service on the given key, unexported for private services.
+func (g *generator) name(key string) string {
+	parts := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var sb strings.Builder
+	for _, p := range parts {
+		r := []rune(p)
+		sb.WriteRune(unicode.ToUpper(r[0]))
+		sb.WriteString(string(r[1:]))
+	}
+	base := sb.String()
+	if base == "" || unicode.IsDigit([]rune(base)[0]) {
+		base = "Service" + base
+	}
+	if g.defs[key].Private {
+		r := []rune(base)
+		base = string(unicode.ToLower(r[0])) + string(r[1:])
+	}
+
+	name := base
+	suffixes := []string{"", "Instance", "Built", "Lock"}
+	used := func(name string) bool {
+		for _, s := range suffixes {
+			if g.used[name+s] || (s != "" && g.used[field(name, s)]) {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 2; used(name); i++ {
+		name = base + strconv.Itoa(i)
+	}
+	g.used[name] = true
+	for _, s := range suffixes[1:] {
+		g.used[field(name, s)] = true
+	}
+
+	return name
+}
+
+// field returns the name of a field of the generated container related to the given method.
+func field(method, suffix string) string {
+	r := []rune(method)
+	return string(unicode.ToLower(r[0])) + string(r[1:]) + suffix
+}
+
+// qualifier returns the name used to reference the package with the given import path, importing it if needed. It
+// returns an empty string for the package of the generated file.
+func (g *generator) qualifier(pkgPath string) string {
+	if pkgPath == g.opts.PkgPath {
+		return ""
+	}
+
+	if name, ok := g.imports[pkgPath]; ok {
+		return name
+	}
+
+	base := path.Base(pkgPath)
+	name := base
+	taken := func(n string) bool {
+		for _, v := range g.imports {
+			if v == n {
+				return true
+			}
+		}
+		return n == g.opts.Package
+	}
+	for i := 2; taken(name); i++ {
+		name = base + strconv.Itoa(i)
+	}
+	g.imports[pkgPath] = name
+
+	return name
+}
+
+// qualify returns the given name qualified by the package with the given import path.
+func (g *generator) qualify(pkgPath, name string) string {
+	if q := g.qualifier(pkgPath); q != "" {
+		return q + "." + name
+	}
+	return name
+}
+
+// typeExpr returns the Go expression of the given type, importing its packages.
+func (g *generator) typeExpr(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name(), nil
+		}
+		if strings.ContainsAny(t.Name(), "[]") {
+			return "", fmt.Errorf("generic type %s is not supported", t)
+		}
+		if t.PkgPath() != g.opts.PkgPath && !isExported(t.Name()) {
+			return "", fmt.Errorf("unexported type %s can not be referenced from package %s", t, g.opts.PkgPath)
+		}
+		return g.qualify(t.PkgPath(), t.Name()), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Chan:
+		elem, err := g.typeExpr(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		switch t.Kind() {
+		case reflect.Ptr:
+			return "*" + elem, nil
+		case reflect.Slice:
+			return "[]" + elem, nil
+		case reflect.Array:
+			return fmt.Sprintf("[%d]%s", t.Len(), elem), nil
+		}
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			return "<-chan " + elem, nil
+		case reflect.SendDir:
+			return "chan<- " + elem, nil
+		}
+		return "chan " + elem, nil
+	case reflect.Map:
+		key, err := g.typeExpr(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := g.typeExpr(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("map[%s]%s", key, elem), nil
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", nil
+		}
+	}
+
+	return "", fmt.Errorf("unnamed type %s is not supported", t)
+}
+
+// isExported returns if the given identifier is exported.
+func isExported(name string) bool {
+	r := []rune(name)
+	return len(r) > 0 && unicode.IsUpper(r[0])
+}
+
+// literal returns the Go literal of the given value if it's of a basic type.
+func (g *generator) literal(v interface{}) (string, bool) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return "", false
+	}
+
+	var lit, def string
+	switch rv.Kind() {
+	case reflect.Bool:
+		lit, def = strconv.FormatBool(rv.Bool()), "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		lit, def = strconv.FormatInt(rv.Int(), 10), "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		lit = strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false
+		}
+		lit, def = strconv.FormatFloat(f, 'g', -1, 64), "float64"
+		if !strings.ContainsAny(lit, ".e") {
+			lit += ".0"
+		}
+	case reflect.String:
+		lit, def = strconv.Quote(rv.String()), "string"
+	default:
+		return "", false
+	}
+
+	if rv.Type().Name() == def && rv.Type().PkgPath() == "" {
+		return lit, true
+	}
+
+	t, err := g.typeExpr(rv.Type())
+	if err != nil {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s(%s)", t, lit), true
+}
+
+// funcName returns the expression to call the given factory directly if it's a named function which can be referenced
+// from the generated package.
+func (g *generator) funcName(f func(Container) interface{}) (string, bool) {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "", false
+	}
+
+	full := fn.Name()
+	slash := strings.LastIndex(full, "/")
+	dot := strings.Index(full[slash+1:], ".")
+	if dot < 0 {
+		return "", false
+	}
+	pkgPath, name := full[:slash+1+dot], full[slash+2+dot:]
+	if strings.ContainsAny(name, ".[]()*") || pkgPath == diPkgPath && g.opts.PkgPath != diPkgPath {
+		return "", false
+	}
+	if pkgPath != g.opts.PkgPath && (!isExported(name) || pkgPath == "main") {
+		return "", false
+	}
+
+	return g.qualify(pkgPath, name), true
+}
+
+// returnType returns the type expression of the service on the given key, or interface{} if unknown.
+func (g *generator) returnType(key string) string {
+	if t, ok := g.types[key]; ok {
+		return t
+	}
+
+	d := g.defs[key]
+	t := "interface{}"
+	var rt reflect.Type
+	switch d.Kind {
+	case TagValue:
+		if v := d.Factory(nil); v != nil {
+			rt = reflect.TypeOf(v)
+		}
+	case TagInject:
+		rt = d.Injection.Type
+		if d.Injection.IsPtr {
+			rt = reflect.PtrTo(rt)
+		}
+	case TagSynthetic:
+		rt = d.Type
+	case TagAlias:
+		if target := g.keyOf(d.AliasOf); target != "" {
+			t = g.returnType(target)
+		}
+	}
+
+	if rt != nil {
+		if expr, err := g.typeExpr(rt); err == nil {
+			t = expr
+		}
+	}
+	g.types[key] = t
+
+	return t
+}
+
+// factory returns the expression calling the factory of the given key retrieved from the builder.
+func (g *generator) factory(key string) string {
+	g.factories = append(g.factories, key)
+	return fmt.Sprintf("c.factories[%q](c)", key)
+}
+
+// convert returns the given expression of the given type converted to the wanted one.
+func convert(expr, typ, want string) string {
+	switch {
+	case typ == want || want == "interface{}":
+		return expr
+	case typ == "interface{}":
+		return fmt.Sprintf("%s.(%s)", expr, want)
+	}
+	return fmt.Sprintf("interface{}(%s).(%s)", expr, want)
+}
+
+// expr returns the expression building the service on the given key.
+func (g *generator) expr(key string) (string, error) {
+	d := g.defs[key]
+	typ := g.returnType(key)
+
+	if d.Locator != nil {
+		return "", fmt.Errorf("locator with key '%s' is not supported", key)
+	}
+
+	switch d.Kind {
+	case TagValue:
+		if lit, ok := g.literal(d.Factory(nil)); ok {
+			return lit, nil
+		}
+		return convert(g.factory(key), "interface{}", typ), nil
+
+	case TagAlias:
+		target := g.keyOf(d.AliasOf)
+		if target == "" {
+			return g.factory(key), nil
+		}
+		return fmt.Sprintf("c.%s()", g.names[target]), nil
+
+	case TagSynthetic:
+		g.synthetic = true
+		return convert(fmt.Sprintf("c.synthetic(%q)", key), "interface{}", typ), nil
+
+	case TagInject:
+		return g.injection(key, d.Injection)
+	}
+
+	if name, ok := g.funcName(d.Factory); ok {
+		return fmt.Sprintf("%s(c)", name), nil
+	}
+
+	return g.factory(key), nil
+}
+
+// injection returns the struct literal building the injectable on the given key.
+func (g *generator) injection(key string, inj *injection) (string, error) {
+	st, err := g.typeExpr(inj.Type)
+	if err != nil {
+		return "", fmt.Errorf("injectable with key '%s' is not supported: %s", key, err)
+	}
+
+	indexes := make([]int, 0, len(inj.Fields))
+	for i := range inj.Fields {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var sb strings.Builder
+	if inj.IsPtr {
+		sb.WriteString("&")
+	}
+	sb.WriteString(st + "{\n")
+	for _, i := range indexes {
+		k := inj.Fields[i]
+		f := inj.Type.Field(i)
+		if strings.HasPrefix(k, locatorPrefix) {
+			return "", fmt.Errorf("locator injected in field %s of service '%s' is not supported", f.Name, key)
+		}
+
+		ft, err := g.typeExpr(f.Type)
+		if err != nil {
+			return "", fmt.Errorf("field %s of service '%s' is not supported: %s", f.Name, key, err)
+		}
+
+		dep := fmt.Sprintf("c.get(%q)", k)
+		depType := "interface{}"
+		if _, ok := g.defs[k]; ok && !g.defs[k].Abstract {
+			dep, depType = fmt.Sprintf("c.%s()", g.names[k]), g.returnType(k)
+		}
+		if f.Type.Kind() == reflect.Interface && ft != "interface{}" && depType != "interface{}" && depType != ft {
+			depType = "interface{}"
+			dep = fmt.Sprintf("interface{}(%s)", dep)
+		}
+		fmt.Fprintf(&sb, "%s: %s,\n", f.Name, convert(dep, depType, ft))
+	}
+	sb.WriteString("}")
+
+	return sb.String(), nil
+}
+
+// doc writes the doc comment of the methods of the service on the given key.
+func (g *generator) doc(w *bytes.Buffer, key, verb string) {
+	fmt.Fprintf(w, "// %s %s the service with key %q.\n", g.names[key], verb, key)
+	if d := g.defs[key]; d.HasTag(TagDeprecated) {
+		fmt.Fprintf(w, "//\n// Deprecated: %s\n", orDash(d.GetTag(TagDeprecated)))
+	}
+}
+
+// method writes the method of the container returning the service on the given key, built by an unsealed view. Private
+// services have no method, as they are only retrieved by the view.
+func (g *generator) method(w *bytes.Buffer, key string) {
+	if g.defs[key].Private {
+		return
+	}
+
+	g.doc(w, key, "returns")
+	name := g.names[key]
+	fmt.Fprintf(w, "func (c *%s) %s() %s {\nreturn c.unsealed().%s()\n}\n\n", g.opts.Type, name, g.returnType(key), name)
+}
+
+// build writes the method of the unsealed view building the service on the given key. It panics if the service is
+// already being built, unless it's a literal, and shared services are stored and flagged as built while holding their
+// lock, so they are built again if the previous build panicked.
+func (g *generator) build(w *bytes.Buffer, key string) error {
+	d := g.defs[key]
+	name := g.names[key]
+
+	expr, err := g.expr(key)
+	if err != nil {
+		return err
+	}
+
+	g.doc(w, key, "builds")
+	fmt.Fprintf(w, "func (c *%s) %s() %s {\n", field(g.opts.Type, "Unsealed"), name, g.returnType(key))
+	if !g.isLiteral(key) {
+		fmt.Fprintf(w, "c = c.enter(%q)\n", key)
+	}
+	if d.Shared {
+		g.shared = true
+		instance, built, lock := field(name, "Instance"), field(name, "Built"), field(name, "Lock")
+		fmt.Fprintf(w, "c.%s.Lock()\ndefer c.%s.Unlock()\n\n", lock, lock)
+		fmt.Fprintf(w, "if !c.%s {\nc.%s = %s\nc.%s = true\n}\n\n", built, instance, expr, built)
+		fmt.Fprintf(w, "return c.%s\n}\n\n", instance)
+		return nil
+	}
+	fmt.Fprintf(w, "return %s\n}\n\n", expr)
+
+	return nil
+}
+
+// isLiteral returns if the service on the given key is a value written as a literal.
+func (g *generator) isLiteral(key string) bool {
+	d := g.defs[key]
+	if d.Kind != TagValue {
+		return false
+	}
+	_, ok := g.literal(d.Factory(nil))
+
+	return ok
+}
+
+// get writes the Get method of the container, which panics for private services and retrieves the rest from an
+// unsealed view.
+func (g *generator) get(w *bytes.Buffer) {
+	fmt.Fprintf(w, "// Get retrieves a service from the container by a given key. It panics if the service is not found or if it\n")
+	fmt.Fprintf(w, "// is private.\n")
+	fmt.Fprintf(w, "func (c *%s) Get(key string) interface{} {\n", g.opts.Type)
+	private := make([]string, 0)
+	for _, k := range g.keys {
+		if g.defs[k].Private {
+			private = append(private, strconv.Quote(k))
+		}
+	}
+	if len(private) > 0 {
+		fmt.Fprintf(w, "switch key {\ncase %s:\n", strings.Join(private, ", "))
+		fmt.Fprintf(w, "panic(fmt.Sprintf(\"service with key '%%s' is private and can't be retrieved from the container\", key))\n")
+		fmt.Fprintf(w, "}\n\n")
+	}
+	fmt.Fprintf(w, "return c.unsealed().get(key)\n}\n\n")
+}
+
+// getTaggedBy writes the table of tagged services, sorted by priority, and the GetTaggedBy method of the container. The
+// priority attributes of tag values are not considered, so services are sorted by their definition's priority.
+func (g *generator) getTaggedBy(w *bytes.Buffer) {
+	tags := make(map[string]bool)
+	for _, k := range g.keys {
+		for t := range g.defs[k].Tags {
+			tags[t] = true
+		}
+	}
+	names := make([]string, 0, len(tags))
+	for t := range tags {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+
+	table := field(g.opts.Type, "Tags")
+	fmt.Fprintf(w, "// %s are the keys and tag values of the services related to each tag, sorted by priority.\n", table)
+	fmt.Fprintf(w, "var %s = map[string][][]string{\n", table)
+	for _, t := range names {
+		fmt.Fprintf(w, "%q: {", t)
+		for _, k := range g.taggedKeys(t) {
+			fmt.Fprintf(w, "{%q", k)
+			for _, v := range g.defs[k].GetTagValues(t) {
+				fmt.Fprintf(w, ", %q", v)
+			}
+			fmt.Fprintf(w, "}, ")
+		}
+		fmt.Fprintf(w, "},\n")
+	}
+	fmt.Fprintf(w, "}\n\n")
+
+	fmt.Fprintf(w, "// GetTaggedBy returns all services related to a given tag. If values provided, then only the services which\n")
+	fmt.Fprintf(w, "// match with tag and value will be returned. Services are sorted by priority.\n")
+	fmt.Fprintf(w, "func (c *%s) GetTaggedBy(tag string, values ...string) []interface{} {\n", g.opts.Type)
+	fmt.Fprintf(w, "return c.taggedBy(c.Get, tag, values)\n}\n\n")
+
+	fmt.Fprintf(w, "// taggedBy returns the services related to a given tag, and any of the values if provided, retrieved with the\n")
+	fmt.Fprintf(w, "// given function.\n")
+	fmt.Fprintf(w, "func (c *%s) taggedBy(get func(string) interface{}, tag string, values []string) []interface{} {\n",
+		g.opts.Type)
+	fmt.Fprintf(w, "services := make([]interface{}, 0, len(%s[tag]))\n", table)
+	fmt.Fprintf(w, "for _, t := range %s[tag] {\n", table)
+	fmt.Fprintf(w, "match := len(values) == 0\nfor _, v := range values {\nfor _, tv := range t[1:] {\nmatch = match || v == tv\n}\n}\n")
+	fmt.Fprintf(w, "if match {\nservices = append(services, get(t[0]))\n}\n}\n\nreturn services\n}\n\n")
+}
+
+// unsealed writes the unsealed view of the container given to factories, which can retrieve private services and
+// keeps the keys of the services being built, and its methods to retrieve services.
+func (g *generator) unsealed(w *bytes.Buffer) {
+	view := field(g.opts.Type, "Unsealed")
+	fmt.Fprintf(w, "// %s is the view of %s given to factories, which can also retrieve the private services.\n",
+		view, g.opts.Type)
+	fmt.Fprintf(w, "// It keeps the keys of the services being built to detect circular references.\n")
+	fmt.Fprintf(w, "type %s struct {\n*%s\nloading []string\n}\n\n", view, g.opts.Type)
+	fmt.Fprintf(w, "// unsealed returns a view of the container which isn't building any service.\n")
+	fmt.Fprintf(w, "func (c *%s) unsealed() *%s {\nreturn &%s{%s: c}\n}\n\n", g.opts.Type, view, view, g.opts.Type)
+	fmt.Fprintf(w, "// enter returns a view building the service on the given key. It panics if the service is already being\n")
+	fmt.Fprintf(w, "// built by the view.\n")
+	fmt.Fprintf(w, "func (c *%s) enter(key string) *%s {\nfor _, k := range c.loading {\nif k == key {\n", view, view)
+	fmt.Fprintf(w, "msg := \"circular reference found while building service '%%s' at service '%%s'\"\n")
+	fmt.Fprintf(w, "panic(fmt.Sprintf(msg, c.loading[0], c.loading[len(c.loading)-1]))\n}\n}\n\n")
+	fmt.Fprintf(w, "loading := append(make([]string, 0, len(c.loading)+1), c.loading...)\n")
+	fmt.Fprintf(w, "return &%s{%s: c.%s, loading: append(loading, key)}\n}\n\n", view, g.opts.Type, g.opts.Type)
+	fmt.Fprintf(w, "// Get retrieves a service from the container by a given key, including the private ones.\n")
+	fmt.Fprintf(w, "func (c *%s) Get(key string) interface{} {\nreturn c.get(key)\n}\n\n", view)
+	fmt.Fprintf(w, "// GetTaggedBy returns all services related to a given tag, including the private ones.\n")
+	fmt.Fprintf(w, "func (c *%s) GetTaggedBy(tag string, values ...string) []interface{} {\n", view)
+	fmt.Fprintf(w, "return c.taggedBy(c.get, tag, values)\n}\n\n")
+	fmt.Fprintf(w, "// get retrieves a service from the container by a given key, including the private ones. It panics if the\n")
+	fmt.Fprintf(w, "// service is not found.\n")
+	fmt.Fprintf(w, "func (c *%s) get(key string) interface{} {\nswitch key {\n", view)
+	for _, k := range g.keys {
+		fmt.Fprintf(w, "case %q:\nreturn c.%s()\n", k, g.names[k])
+	}
+	fmt.Fprintf(w, "}\n\npanic(fmt.Sprintf(\"service with key '%%s' not found\", key))\n}\n\n")
+}
+
+// taggedKeys returns the keys of the services with the given tag sorted as the builder does, so the generated code
+// is stable.
+func (g *generator) taggedKeys(tag string) []string {
+	keys := make([]string, 0)
+	for _, k := range g.keys {
+		if g.defs[k].HasTag(tag) {
+			keys = append(keys, k)
+		}
+	}
+	g.builder.sortTagged(keys, tag, nil)
+
+	return keys
+}
+
+// provide writes the methods to provide and retrieve synthetic services. Values are checked with a type assertion on
+// the declared type of the synthetic service, if it can be referenced from the generated code.
+func (g *generator) provide(w *bytes.Buffer) {
+	fmt.Fprintf(w, "// Provide sets the value of a synthetic service on current container. It panics if the definition on the given\n")
+	fmt.Fprintf(w, "// key is not synthetic or if the value is not of the declared type of the synthetic service.\n")
+	fmt.Fprintf(w, "func (c *%s) Provide(key string, value interface{}) {\nswitch key {\n", g.opts.Type)
+	for _, k := range g.keys {
+		d := g.defs[k]
+		if d.Kind != TagSynthetic {
+			continue
+		}
+		fmt.Fprintf(w, "case %q:\n", k)
+		if typ := g.returnType(k); d.Type != nil && typ != "interface{}" {
+			fmt.Fprintf(w, "if _, ok := value.(%s); !ok {\n", typ)
+			fmt.Fprintf(w, "msg := \"value of type %%T can't be provided for synthetic service with key '%%s' of type %%s\"\n")
+			fmt.Fprintf(w, "panic(fmt.Sprintf(msg, value, key, %q))\n}\n", d.Type.String())
+		}
+	}
+	fmt.Fprintf(w, "default:\n")
+	fmt.Fprintf(w, "panic(fmt.Sprintf(\"service with key '%%s' is not synthetic and can't be provided\", key))\n}\n\n")
+	fmt.Fprintf(w, "c.synthetics.Store(key, value)\n}\n\n")
+	fmt.Fprintf(w, "// synthetic returns the value provided for a synthetic service or panics if it has not been provided yet.\n")
+	fmt.Fprintf(w, "func (c *%s) synthetic(key string) interface{} {\n", g.opts.Type)
+	fmt.Fprintf(w, "v, ok := c.synthetics.Load(key)\nif !ok {\n")
+	fmt.Fprintf(w, "panic(fmt.Sprintf(\"synthetic service with key '%%s' has not been provided to the container\", key))\n")
+	fmt.Fprintf(w, "}\n\nreturn v\n}\n")
+}
+
+// header writes the imports, the container type with its fields and its constructor.
+func (g *generator) header(w *bytes.Buffer) {
+	if g.shared || g.synthetic {
+		g.imports["sync"] = "sync"
+	}
+	di := g.qualify(diPkgPath, "")
+
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	sort.SliceStable(paths, func(i, j int) bool {
+		return !strings.Contains(paths[i], ".") && strings.Contains(paths[j], ".")
+	})
+	fmt.Fprintf(w, "import (\n")
+	for i, p := range paths {
+		if i > 0 && strings.Contains(p, ".") && !strings.Contains(paths[i-1], ".") {
+			fmt.Fprintf(w, "\n")
+		}
+		if name := g.imports[p]; name != path.Base(p) {
+			fmt.Fprintf(w, "%s %q\n", name, p)
+			continue
+		}
+		fmt.Fprintf(w, "%q\n", p)
+	}
+	fmt.Fprintf(w, ")\n\n")
+
+	fmt.Fprintf(w, "var _ %sContainer = (*%s)(nil)\n\n", di, g.opts.Type)
+
+	fmt.Fprintf(w, "// %s is a container generated from a builder, with a method per service.\n", g.opts.Type)
+	fmt.Fprintf(w, "type %s struct {\n", g.opts.Type)
+	fmt.Fprintf(w, "factories map[string]func(%sContainer) interface{}\n", di)
+	if g.synthetic {
+		fmt.Fprintf(w, "synthetics sync.Map\n")
+	}
+	for _, k := range g.keys {
+		if g.defs[k].Shared {
+			name := g.names[k]
+			fmt.Fprintf(w, "%s %s\n%s bool\n%s sync.Mutex\n", field(name, "Instance"), g.returnType(k),
+				field(name, "Built"), field(name, "Lock"))
+		}
+	}
+	fmt.Fprintf(w, "}\n\n")
+
+	fmt.Fprintf(w, "// New%s returns a new %s. The builder is resolved to retrieve the factories and values which\n",
+		g.opts.Type, g.opts.Type)
+	fmt.Fprintf(w, "// couldn't be generated as code, so it must be configured as the one used to generate the container.\n")
+	fmt.Fprintf(w, "func New%s(b %sContainerBuilder) *%s {\n", g.opts.Type, di, g.opts.Type)
+	fmt.Fprintf(w, "b.GetContainer()\n\n")
+	fmt.Fprintf(w, "factory := func(key string) func(%sContainer) interface{} {\n", di)
+	fmt.Fprintf(w, "d := b.GetDefinition(key)\nif d == nil {\n")
+	fmt.Fprintf(w, "panic(fmt.Sprintf(\"definition with id '%%s' does not exist\", key))\n")
+	fmt.Fprintf(w, "}\nreturn d.GetFactory()\n}\n\n")
+	fmt.Fprintf(w, "return &%s{\nfactories: map[string]func(%sContainer) interface{}{\n", g.opts.Type, di)
+	for _, k := range g.factories {
+		fmt.Fprintf(w, "%q: factory(%q),\n", k, k)
+	}
+	fmt.Fprintf(w, "},\n}\n}\n\n")
+}
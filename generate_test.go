@@ -0,0 +1,276 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the generated container")
+
+type generatedMailer struct {
+	From    string        `inject:"email.from"`
+	Timeout time.Duration `inject:"email.timeout"`
+	Client  Container     `inject:"email.client"`
+}
+
+type generatedA struct {
+	B interface{} `inject:"b"`
+}
+
+type generatedB struct {
+	A interface{} `inject:"a"`
+}
+
+type generatedRepo struct {
+	Name string
+}
+
+func newGeneratedClient(c Container) interface{} {
+	return c
+}
+
+func newGeneratedUsesPrivate(c Container) interface{} {
+	return c.Get("repo").(*generatedRepo).Name
+}
+
+func newGeneratedHandlers(c Container) interface{} {
+	return len(c.GetTaggedBy("handler"))
+}
+
+// newGeneratedBuilder returns the builder used to generate the container of generated_container_test.go.
+func newGeneratedBuilder() *containerBuilder {
+	b := newContainerBuilder()
+	b.SetValue("repo #private", &generatedRepo{Name: "repo"})
+	b.SetFactory("uses.private", newGeneratedUsesPrivate)
+	b.SetFactory("uses.private.closure", func(c Container) interface{} {
+		return c.Get("repo").(*generatedRepo).Name + ".closure"
+	})
+	b.SetValue("handler.a #handler #private", "a")
+	b.SetValue("handler.b #handler #priority=1", "b")
+	b.SetFactory("handlers", newGeneratedHandlers)
+	b.SetValue("email.from", "from@email.com")
+	b.SetValue("email.timeout #private", 5*time.Second)
+	b.SetFactory("email.client #shared", newGeneratedClient)
+	b.SetInjectable("email.mailer #shared", &generatedMailer{})
+	b.SetSynthetic("request.timeout", reflect.TypeOf(time.Duration(0)))
+
+	calls := 0
+	b.SetFactory("flaky #shared", func(c Container) interface{} {
+		calls++
+		if calls == 1 {
+			panic("flaky failed")
+		}
+		return calls
+	})
+	b.SetFactory("self #shared", func(c Container) interface{} {
+		return c.Get("self")
+	})
+
+	return b
+}
+
+func TestGenerate(t *testing.T) {
+	opts := GenerateOptions{Package: "di", PkgPath: diPkgPath, Type: "Generated"}
+
+	t.Run("generates a method per service", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("email.from", "from@email.com")
+		b.SetValue("email.timeout #private", 5*time.Second)
+		b.SetValue("email.hosts", []string{"localhost"})
+		b.SetFactory("email.client #shared", newGeneratedClient)
+		b.SetFactory("listener.one #listener=a", func(c Container) interface{} {
+			return 1
+		})
+		b.SetFactory("listener.two #listener=b #priority=2 #deprecated=use one", func(c Container) interface{} {
+			return 2
+		})
+		b.SetInjectable("email.mailer #shared", &generatedMailer{})
+		b.SetAlias("mailer", "email.mailer")
+		b.SetSynthetic("request", nil)
+		b.SetFactory("abstract #abstract", newGeneratedClient)
+
+		src, err := Generate(b, opts)
+
+		assert.Nil(t, err)
+		code := string(src)
+		assert.Contains(t, code, "// Code generated by github.com/golossus/di. DO NOT EDIT.\n\npackage di\n")
+		assert.Contains(t, code, "import (\n\t\"fmt\"\n\t\"sync\"\n\t\"time\"\n)")
+		assert.Contains(t, code, "var _ Container = (*Generated)(nil)")
+		assert.Contains(t, code, "func NewGenerated(b ContainerBuilder) *Generated {")
+		assert.Contains(t, code, "\"email.hosts\":  factory(\"email.hosts\"),")
+		assert.Contains(t, code, "func (c *Generated) EmailFrom() string {\n\treturn c.unsealed().EmailFrom()\n}")
+		assert.Contains(t, code, "func (c *generatedUnsealed) EmailFrom() string {\n\treturn \"from@email.com\"\n}")
+		assert.Contains(t, code, "func (c *generatedUnsealed) emailTimeout() time.Duration {\n\treturn time.Duration(5000000000)\n}")
+		assert.NotContains(t, code, "func (c *Generated) emailTimeout()")
+		assert.Contains(t, code, "func (c *generatedUnsealed) EmailHosts() []string {\n\tc = c.enter(\"email.hosts\")\n\t"+
+			"return c.factories[\"email.hosts\"](c).([]string)\n}")
+		assert.Contains(t, code, "if !c.emailClientBuilt {\n\t\tc.emailClientInstance = newGeneratedClient(c)\n\t\t"+
+			"c.emailClientBuilt = true\n\t}")
+		assert.Contains(t, code, "c.emailMailerInstance = &generatedMailer{\n\t\t\tFrom:    c.EmailFrom(),\n\t\t\t"+
+			"Timeout: c.emailTimeout(),\n\t\t\tClient:  c.EmailClient().(Container),\n\t\t}")
+		assert.Contains(t, code, "func (c *generatedUnsealed) Mailer() *generatedMailer {\n\tc = c.enter(\"mailer\")\n\t"+
+			"return c.EmailMailer()\n}")
+		assert.Contains(t, code, "func (c *generatedUnsealed) ListenerOne() interface{} {\n\tc = c.enter(\"listener.one\")\n\t"+
+			"return c.factories[\"listener.one\"](c)\n}")
+		assert.Contains(t, code, "// Deprecated: use one\nfunc (c *Generated) ListenerTwo() interface{} {")
+		assert.Contains(t, code, "func (c *generatedUnsealed) Request() interface{} {\n\tc = c.enter(\"request\")\n\t"+
+			"return c.synthetic(\"request\")\n}")
+		assert.Contains(t, code, "case \"email.timeout\":\n\t\tpanic(")
+		assert.Contains(t, code, "case \"email.timeout\":\n\t\treturn c.emailTimeout()")
+		assert.Contains(t, code, "func (c *generatedUnsealed) Get(key string) interface{} {\n\treturn c.get(key)\n}")
+		assert.Contains(t, code, "\"listener\":   {{\"listener.two\", \"b\"}, {\"listener.one\", \"a\"}},")
+		assert.Contains(t, code, "func (c *Generated) Provide(key string, value interface{}) {\n\tswitch key {\n\t"+
+			"case \"request\":\n\tdefault:\n\t\tpanic(")
+		assert.NotContains(t, code, "Abstract")
+	})
+
+	t.Run("generates a container working as the reflective one", func(t *testing.T) {
+		src, err := Generate(newGeneratedBuilder(), opts)
+		assert.Nil(t, err)
+
+		if *update {
+			assert.Nil(t, ioutil.WriteFile("generated_container_test.go", src, 0644))
+		}
+
+		expected, err := ioutil.ReadFile("generated_container_test.go")
+		assert.Nil(t, err)
+		assert.Equal(t, string(expected), string(src))
+
+		b := newGeneratedBuilder()
+		gc := NewGenerated(b)
+		rc := b.GetContainer()
+
+		for _, k := range []string{"uses.private", "uses.private.closure", "handlers", "email.from"} {
+			assert.Equal(t, rc.Get(k), gc.Get(k), k)
+		}
+		assert.Equal(t, "repo", gc.Get("uses.private"))
+		assert.Equal(t, "repo.closure", gc.Get("uses.private.closure"))
+		assert.Equal(t, 2, gc.Get("handlers"))
+
+		mailer := gc.Get("email.mailer").(*generatedMailer)
+		assert.Same(t, mailer, gc.Get("email.mailer"))
+		assert.Equal(t, 5*time.Second, mailer.Timeout)
+		assert.Equal(t, 5*time.Second, mailer.Client.Get("email.timeout"))
+
+		msg := "service with key 'repo' is private and can't be retrieved from the container"
+		assert.PanicsWithValue(t, msg, func() {
+			rc.Get("repo")
+		})
+		assert.PanicsWithValue(t, msg, func() {
+			gc.Get("repo")
+		})
+		assert.Panics(t, func() {
+			rc.GetTaggedBy("handler")
+		})
+		assert.Panics(t, func() {
+			gc.GetTaggedBy("handler")
+		})
+		assert.PanicsWithValue(t, "service with key 'none' not found", func() {
+			gc.Get("none")
+		})
+
+		assert.PanicsWithValue(t, "flaky failed", func() {
+			gc.Get("flaky")
+		})
+		assert.Equal(t, 2, gc.Get("flaky"))
+		assert.Equal(t, 2, gc.Get("flaky"))
+
+		msg = "circular reference found while building service 'self' at service 'self'"
+		assert.PanicsWithValue(t, msg, func() {
+			rc.Get("self")
+		})
+		assert.PanicsWithValue(t, msg, func() {
+			gc.Get("self")
+		})
+
+		type provider interface {
+			Container
+			Provide(key string, value interface{})
+		}
+		for _, c := range []provider{rc, gc} {
+			assert.PanicsWithValue(t, "service with key 'email.from' is not synthetic and can't be provided", func() {
+				c.Provide("email.from", "a")
+			})
+			msg = "value of type string can't be provided for synthetic service with key 'request.timeout' of type " +
+				"time.Duration"
+			assert.PanicsWithValue(t, msg, func() {
+				c.Provide("request.timeout", "a")
+			})
+			c.Provide("request.timeout", time.Second)
+			assert.Equal(t, time.Second, c.Get("request.timeout"))
+		}
+	})
+
+	t.Run("generates unique method names", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("get", 1)
+		b.SetValue("email.from", "a")
+		b.SetValue("email_from", "b")
+		b.SetValue("1st", "c")
+
+		src, err := Generate(b, opts)
+
+		assert.Nil(t, err)
+		code := string(src)
+		assert.Contains(t, code, "func (c *Generated) Get2() int {")
+		assert.Contains(t, code, "func (c *Generated) EmailFrom() string {")
+		assert.Contains(t, code, "func (c *Generated) EmailFrom2() string {")
+		assert.Contains(t, code, "func (c *Generated) Service1st() string {")
+	})
+
+	t.Run("fails if builder is not supported", func(t *testing.T) {
+		_, err := Generate(struct{ ContainerBuilder }{}, opts)
+
+		assert.EqualError(t, err, "builder of type struct { di.ContainerBuilder } is not supported")
+	})
+
+	t.Run("imports the packages of referenced types", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("timeout", 5*time.Second)
+
+		src, err := Generate(b, GenerateOptions{Package: "app", PkgPath: "example.com/app", Type: "App"})
+
+		assert.Nil(t, err)
+		code := string(src)
+		assert.Contains(t, code, "import (\n\t\"fmt\"\n\t\"time\"\n\n\t\"github.com/golossus/di\"\n)")
+		assert.Contains(t, code, "func NewApp(b di.ContainerBuilder) *App {")
+	})
+
+	errors := []struct {
+		name  string
+		build func(b *containerBuilder)
+		err   string
+	}{
+		{"fails on locators", func(b *containerBuilder) {
+			b.SetLocator("locator", "k1")
+		}, "locator with key 'locator' is not supported"},
+		{"fails on circular references", func(b *containerBuilder) {
+			b.SetInjectable("a", generatedA{})
+			b.SetInjectable("b", generatedB{})
+		}, "circular reference found while generating service 'a' at a -> b -> a"},
+		{"fails on unexported types of other packages", func(b *containerBuilder) {
+			b.SetInjectable("mailer", &generatedMailer{})
+		}, "injectable with key 'mailer' is not supported: unexported type di.generatedMailer can not be " +
+			"referenced from package example.com/app"},
+	}
+
+	for _, data := range errors {
+		t.Run(data.name, func(t *testing.T) {
+			b := newContainerBuilder()
+			data.build(b)
+
+			_, err := Generate(b, GenerateOptions{Package: "app", PkgPath: "example.com/app", Type: "App"})
+
+			assert.EqualError(t, err, data.err)
+		})
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// This is the list of kinds of edges between services in a dependency Graph.
+const (
+	EdgeInject  = "inject"
+	EdgeAlias   = "alias"
+	EdgeParent  = "parent"
+	EdgeLocator = "locator"
+	EdgeGet     = "get"
+)
+
+// GraphNode represents a service definition in a dependency Graph.
+type GraphNode struct {
+	Key      string            `json:"key"`
+	Kind     string            `json:"kind"`
+	Shared   bool              `json:"shared"`
+	Private  bool              `json:"private"`
+	Priority int16             `json:"priority"`
+	Tags     map[string]string `json:"tags"`
+}
+
+// GraphEdge represents a dependency from a service to another one. Kind tells how the dependency is known: statically
+// from inject struct tags, aliases, parents and locators; or dynamically from Get calls observed during construction.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Graph is the dependency graph of the services of a container, with nodes and edges sorted by key.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// observedEdges records the dependencies between services observed while building them. It is shared between a
+// container and its unsealed copies.
+type observedEdges struct {
+	edges map[GraphEdge]bool
+	lock  sync.Mutex
+}
+
+// observe records a dependency of the service being built, if any, on the given key.
+func (o *observedEdges) observe(loading []string, key string) {
+	if len(loading) == 0 {
+		return
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.edges[GraphEdge{From: loading[len(loading)-1], To: key, Kind: EdgeGet}] = true
+}
+
+// Graph returns the dependency graph of current container. Static dependencies are always present, while dynamic ones
+// are only present for the services built so far, so calling MustBuild beforehand can give a more complete graph.
+func (c *container) Graph() *Graph {
+	defs := c.builder.definitions
+	g := &Graph{Nodes: make([]GraphNode, 0, len(defs)), Edges: make([]GraphEdge, 0)}
+	static := make(map[[2]string]bool)
+	seen := make(map[GraphEdge]bool)
+	add := func(from, to, kind string) {
+		e := GraphEdge{From: from, To: to, Kind: kind}
+		if !seen[e] {
+			g.Edges = append(g.Edges, e)
+			seen[e] = true
+		}
+		static[[2]string{from, to}] = true
+	}
+	locators := func(from string, entries []string) {
+		for _, e := range entries {
+			if k, _, err := parseKey(e); err == nil && k != "" {
+				add(from, k, EdgeLocator)
+			}
+		}
+	}
+
+	for key, def := range defs {
+		tags := make(map[string]string, len(def.Tags))
+		for t, v := range def.Tags {
+			tags[t] = v
+		}
+
+		g.Nodes = append(g.Nodes, GraphNode{
+			Key:      key,
+			Kind:     def.Kind,
+			Shared:   def.Shared,
+			Private:  def.Private,
+			Priority: def.Priority,
+			Tags:     tags,
+		})
+
+		if def.AliasOf != nil {
+			add(key, def.AliasOf.key, EdgeAlias)
+		}
+		if def.Parent != nil {
+			add(key, def.Parent.key, EdgeParent)
+		}
+		locators(key, def.Locator)
+		if def.Injection == nil {
+			continue
+		}
+		for _, k := range def.Injection.Fields {
+			if strings.HasPrefix(k, locatorPrefix) {
+				locators(key, parseLocatorEntries(k))
+				continue
+			}
+			add(key, k, EdgeInject)
+		}
+	}
+
+	// aliases share the factory of the aliased service, so the dependencies observed while building them belong to it.
+	c.edges.lock.Lock()
+	for e := range c.edges.edges {
+		if d, ok := defs[e.From]; ok && d.AliasOf != nil {
+			continue
+		}
+		if !static[[2]string{e.From, e.To}] {
+			g.Edges = append(g.Edges, e)
+		}
+	}
+	c.edges.lock.Unlock()
+
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].Key < g.Nodes[j].Key
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Kind < b.Kind
+	})
+
+	return g
+}
+
+// label returns a multiline description of the node with its kind, flags, priority and tags. Reserved tags already
+// represented by the node attributes are omitted.
+func (n GraphNode) label(sep string) string {
+	lines := []string{n.Key, n.Kind}
+
+	flags := make([]string, 0, 2)
+	if n.Shared {
+		flags = append(flags, TagShared)
+	}
+	if n.Private {
+		flags = append(flags, TagPrivate)
+	}
+	if n.Priority != 0 {
+		flags = append(flags, fmt.Sprintf("%s=%d", TagPriority, n.Priority))
+	}
+	if len(flags) > 0 {
+		lines = append(lines, strings.Join(flags, " "))
+	}
+
+	tags := make([]string, 0, len(n.Tags))
+	for t, v := range n.Tags {
+		if t == n.Kind || t == TagShared || t == TagPrivate || t == TagPriority {
+			continue
+		}
+		if v != "" {
+			t += "=" + v
+		}
+		tags = append(tags, "#"+t)
+	}
+	if len(tags) > 0 {
+		sort.Strings(tags)
+		lines = append(lines, strings.Join(tags, " "))
+	}
+
+	return strings.Join(lines, sep)
+}
+
+// DOT returns the graph in Graphviz DOT format. Shared services are drawn in bold and private ones dashed.
+func (g *Graph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph di {\n")
+	sb.WriteString("\tnode [shape=box];\n")
+
+	for _, n := range g.Nodes {
+		styles := make([]string, 0, 2)
+		if n.Shared {
+			styles = append(styles, "bold")
+		}
+		if n.Private {
+			styles = append(styles, "dashed")
+		}
+
+		label := strings.Replace(dotEscape(n.label("\n")), "\n", `\n`, -1)
+		sb.WriteString(fmt.Sprintf("\t\"%s\" [label=\"%s\"", dotEscape(n.Key), label))
+		if len(styles) > 0 {
+			sb.WriteString(fmt.Sprintf(" style=\"%s\"", strings.Join(styles, ",")))
+		}
+		sb.WriteString("];\n")
+	}
+
+	for _, e := range g.Edges {
+		from, to := dotEscape(e.From), dotEscape(e.To)
+		sb.WriteString(fmt.Sprintf("\t\"%s\" -> \"%s\" [label=\"%s\"];\n", from, to, dotEscape(e.Kind)))
+	}
+
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+// dotEscape escapes the double quotes and backslashes of the given string to be written in a DOT quoted string, which
+// doesn't support the rest of Go escape sequences.
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// Mermaid returns the graph as a Mermaid flowchart. Nodes use generated ids because keys may contain characters not
+// supported by Mermaid.
+func (g *Graph) Mermaid() string {
+	ids := make(map[string]string)
+	id := func(key string) string {
+		if _, ok := ids[key]; !ok {
+			ids[key] = fmt.Sprintf("n%d", len(ids))
+		}
+		return ids[key]
+	}
+
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+
+	for _, n := range g.Nodes {
+		label := strings.Replace(n.label("<br/>"), `"`, "#quot;", -1)
+		sb.WriteString(fmt.Sprintf("\t%s[\"%s\"]\n", id(n.Key), label))
+	}
+
+	for _, e := range g.Edges {
+		for _, k := range []string{e.From, e.To} {
+			if _, ok := ids[k]; !ok {
+				sb.WriteString(fmt.Sprintf("\t%s[\"%s\"]\n", id(k), strings.Replace(k, `"`, "#quot;", -1)))
+			}
+		}
+	}
+
+	for _, e := range g.Edges {
+		sb.WriteString(fmt.Sprintf("\t%s -->|%s| %s\n", id(e.From), e.Kind, id(e.To)))
+	}
+
+	return sb.String()
+}
+
+// JSON returns the graph encoded as indented JSON.
+func (g *Graph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
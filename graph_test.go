@@ -0,0 +1,142 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newGraphContainer() *container {
+	type Mailer struct {
+		From    string    `inject:"from"`
+		Plugins Container `inject:"locator:plugin,#tag"`
+	}
+
+	b := newContainerBuilder()
+	b.SetValue("from #private", "me")
+	b.SetValue("plugin", 1)
+	b.SetInjectable("mailer #shared #priority=2 #channel=email", &Mailer{})
+	b.SetAlias("mailer.default", "mailer")
+	b.SetFactory("base #abstract", dummyFactory)
+	b.SetChild("child", "base")
+	b.SetFactory("sender", func(c Container) interface{} {
+		return c.Get("mailer.default")
+	})
+
+	return b.resolve()
+}
+
+func TestContainer_Graph(t *testing.T) {
+	t.Run("includes static dependencies", func(t *testing.T) {
+		g := newGraphContainer().Graph()
+
+		assert.Len(t, g.Nodes, 7)
+		assert.Equal(t, GraphNode{
+			Key:      "mailer",
+			Kind:     TagInject,
+			Shared:   true,
+			Priority: 2,
+			Tags:     map[string]string{TagInject: "", TagShared: "", TagPriority: "2", "channel": "email"},
+		}, g.Nodes[3])
+		assert.Equal(t, []GraphEdge{
+			{From: "child", To: "base", Kind: EdgeParent},
+			{From: "mailer", To: "from", Kind: EdgeInject},
+			{From: "mailer", To: "plugin", Kind: EdgeLocator},
+			{From: "mailer.default", To: "mailer", Kind: EdgeAlias},
+		}, g.Edges)
+	})
+
+	t.Run("links aliases and children to overwritten definitions by key", func(t *testing.T) {
+		b := newContainerBuilder()
+		b.SetValue("target", 1)
+		b.SetAlias("alias", "target")
+		b.SetFactory("base #abstract", dummyFactory)
+		b.SetChild("child", "base")
+		b.SetValue("target", 2)
+		b.SetValue("base", 3)
+
+		assert.Equal(t, []GraphEdge{
+			{From: "alias", To: "target", Kind: EdgeAlias},
+			{From: "child", To: "base", Kind: EdgeParent},
+		}, b.resolve().Graph().Edges)
+	})
+
+	t.Run("includes dynamic dependencies observed", func(t *testing.T) {
+		c := newGraphContainer()
+		c.Get("sender")
+		g := c.Graph()
+
+		assert.Equal(t, []GraphEdge{
+			{From: "child", To: "base", Kind: EdgeParent},
+			{From: "mailer", To: "from", Kind: EdgeInject},
+			{From: "mailer", To: "plugin", Kind: EdgeLocator},
+			{From: "mailer.default", To: "mailer", Kind: EdgeAlias},
+			{From: "sender", To: "mailer.default", Kind: EdgeGet},
+		}, g.Edges)
+	})
+}
+
+func TestGraph_Export(t *testing.T) {
+	g := &Graph{
+		Nodes: []GraphNode{
+			{Key: "a", Kind: TagFactory, Shared: true, Private: true, Priority: 1, Tags: map[string]string{TagFactory: "", "x": "1", "y": ""}},
+			{Key: "b", Kind: TagValue, Tags: map[string]string{TagValue: ""}},
+		},
+		Edges: []GraphEdge{
+			{From: "a", To: "b", Kind: EdgeGet},
+			{From: "a", To: "c", Kind: EdgeInject},
+		},
+	}
+
+	t.Run("exports to DOT", func(t *testing.T) {
+		expected := `digraph di {
+	node [shape=box];
+	"a" [label="a\nfactory\nshared private priority=1\n#x=1 #y" style="bold,dashed"];
+	"b" [label="b\nvalue"];
+	"a" -> "b" [label="get"];
+	"a" -> "c" [label="inject"];
+}
+`
+		assert.Equal(t, expected, g.DOT())
+	})
+
+	t.Run("escapes quotes and backslashes only in DOT", func(t *testing.T) {
+		g := &Graph{
+			Nodes: []GraphNode{{Key: `say "héllo" \ bye`, Kind: TagValue, Tags: map[string]string{TagValue: ""}}},
+			Edges: []GraphEdge{{From: `say "héllo" \ bye`, To: "ñ", Kind: EdgeGet}},
+		}
+
+		expected := `digraph di {
+	node [shape=box];
+	"say \"héllo\" \\ bye" [label="say \"héllo\" \\ bye\nvalue"];
+	"say \"héllo\" \\ bye" -> "ñ" [label="get"];
+}
+`
+		assert.Equal(t, expected, g.DOT())
+	})
+
+	t.Run("exports to Mermaid", func(t *testing.T) {
+		expected := `graph LR
+	n0["a<br/>factory<br/>shared private priority=1<br/>#x=1 #y"]
+	n1["b<br/>value"]
+	n2["c"]
+	n0 -->|get| n1
+	n0 -->|inject| n2
+`
+		assert.Equal(t, expected, g.Mermaid())
+	})
+
+	t.Run("exports to JSON", func(t *testing.T) {
+		data, err := g.JSON()
+		assert.Nil(t, err)
+
+		decoded := &Graph{}
+		assert.Nil(t, json.Unmarshal(data, decoded))
+		assert.Equal(t, g, decoded)
+		assert.Contains(t, string(data), `"kind": "factory"`)
+	})
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// keyEscapable are the characters which can be escaped with a backslash outside quotes. Any other backslash is kept.
+const keyEscapable = "#=\"\\ \t"
+
+// parseKey looks for tags in the given key. Tags can be specified using the '#' char as separator. The value for a tag
+// can be defined by using the '=' char as separator from the tag name and its value. The real key will be the suffix
+// of the given key until the first '#'. This function will trim empty spaces of the found key, tag names and valeus.
+// If a tag is repeated, only its first value is returned, see parseKeyValues.
+// As an Example:
+//
+//	" some.suffix #tag1 = 2 # tag2"
+//
+// Will output:
+//
+//	key  = "some.suffix"
+//	tags = {"tag1": "2", "tag2": ""}
+//
+// Parts containing '#', '=' or surrounding spaces can be quoted with double quotes, where '"' and '\' must be escaped
+// with a backslash, or those characters can be escaped with a backslash outside quotes:
+//
+//	`db #dsn="user:p#ss@tcp(host)/db" #cron=0\ 0\ *\ *\ *`
+//
+// It returns an error with the position of the problem if the key is malformed, e.g. on empty tag names or
+// unterminated quotes.
+func parseKey(raw string) (key string, tags map[string]string, err error) {
+	key, values, err := parseKeyValues(raw)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return key, firstTagValues(values), nil
+}
+
+// parseKeyValues looks for tags in the given key as parseKey does, but it returns all the values of repeated tags in
+// order of appearance, e.g. "listener #event=user.created #event=user.deleted".
+func parseKeyValues(raw string) (key string, tags map[string][]string, err error) {
+	s := &keyScanner{runes: []rune(raw), tags: map[string][]string{}}
+	if err := s.scan(); err != nil {
+		return "", nil, fmt.Errorf("invalid key '%s': %s", raw, err)
+	}
+
+	return s.key, s.tags, nil
+}
+
+// keyPart is the part of a key being scanned.
+type keyPart int
+
+const (
+	keyPartKey keyPart = iota
+	keyPartTag
+	keyPartValue
+)
+
+// keyScanner splits a raw key into the key and its tags.
+type keyScanner struct {
+	runes []rune
+	tags  map[string][]string
+	key   string
+
+	part   keyPart
+	tag    string
+	tagPos int
+	buf    []rune
+	quoted []bool
+}
+
+// scan scans the whole key, returning an error with the position of the first malformed part.
+func (s *keyScanner) scan() error {
+	for i := 0; i < len(s.runes); i++ {
+		r := s.runes[i]
+		switch {
+		case r == '\\' && i+1 < len(s.runes) && strings.ContainsRune(keyEscapable, s.runes[i+1]):
+			i++
+			s.write(s.runes[i], true)
+		case r == '"':
+			end, err := s.quote(i)
+			if err != nil {
+				return err
+			}
+			i = end
+		case r == '#':
+			if err := s.flush(); err != nil {
+				return err
+			}
+			s.part, s.tagPos = keyPartTag, i+1
+		case r == '=' && s.part == keyPartTag:
+			if err := s.flush(); err != nil {
+				return err
+			}
+			s.part = keyPartValue
+		default:
+			s.write(r, false)
+		}
+	}
+
+	return s.flush()
+}
+
+// quote writes the content of the quoted string starting at the given position and returns the position of its
+// closing quote.
+func (s *keyScanner) quote(start int) (int, error) {
+	for i := start + 1; i < len(s.runes); i++ {
+		switch r := s.runes[i]; {
+		case r == '"':
+			return i, nil
+		case r == '\\' && i+1 < len(s.runes) && (s.runes[i+1] == '"' || s.runes[i+1] == '\\'):
+			i++
+			s.write(s.runes[i], true)
+		default:
+			s.write(r, true)
+		}
+	}
+
+	return 0, fmt.Errorf("unterminated quote at position %d", start+1)
+}
+
+// write adds a rune to the part being scanned. Quoted or escaped runes are not trimmed.
+func (s *keyScanner) write(r rune, quoted bool) {
+	s.buf = append(s.buf, r)
+	s.quoted = append(s.quoted, quoted)
+}
+
+// flush ends the part being scanned, trimming its unquoted surrounding spaces. Tags are added with an empty value,
+// replaced when their value is flushed.
+func (s *keyScanner) flush() error {
+	start, end := 0, len(s.buf)
+	for start < end && !s.quoted[start] && unicode.IsSpace(s.buf[start]) {
+		start++
+	}
+	for end > start && !s.quoted[end-1] && unicode.IsSpace(s.buf[end-1]) {
+		end--
+	}
+	text := string(s.buf[start:end])
+	s.buf, s.quoted = s.buf[:0], s.quoted[:0]
+
+	switch s.part {
+	case keyPartKey:
+		s.key = text
+	case keyPartTag:
+		if text == "" {
+			return fmt.Errorf("empty tag name at position %d", s.tagPos)
+		}
+		s.tag = text
+		s.tags[s.tag] = append(s.tags[s.tag], "")
+	case keyPartValue:
+		s.tags[s.tag][len(s.tags[s.tag])-1] = text
+	}
+
+	return nil
+}
+
+// quoteKeyPart returns the given key, tag name or value quoted if it can't be parsed back as it is.
+func quoteKeyPart(part string) string {
+	if !strings.ContainsAny(part, "#=\"\\") && strings.TrimSpace(part) == part {
+		return part
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(part) + `"`
+}
+
+// firstTagValues returns a map with the first value of each tag of the given multi-valued tags.
+func firstTagValues(values map[string][]string) map[string]string {
+	tags := make(map[string]string, len(values))
+	for tag, vs := range values {
+		if len(vs) > 0 {
+			tags[tag] = vs[0]
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		raw  string
+		key  string
+		tags map[string]string
+	}{
+		{"", "", map[string]string{}},
+		{"Key", "Key", map[string]string{}},
+		{" Key ", "Key", map[string]string{}},
+		{" Key #tag1", "Key", map[string]string{"tag1": ""}},
+		{" Key #tag1 #tag2", "Key", map[string]string{"tag1": "", "tag2": ""}},
+		{" Key #tag1=1a #tag2=2b ", "Key", map[string]string{"tag1": "1a", "tag2": "2b"}},
+		{" Key #tag1=1a #tag2=2b cc", "Key", map[string]string{"tag1": "1a", "tag2": "2b cc"}},
+		{" Key #tag1=1a #tag2=2b cc ", "Key", map[string]string{"tag1": "1a", "tag2": "2b cc"}},
+		{" Key #tag1 #tag2 3 4 ", "Key", map[string]string{"tag1": "", "tag2 3 4": ""}},
+		{" Key #tag1 =1a #tag2 = 2b ", "Key", map[string]string{"tag1": "1a", "tag2": "2b"}},
+		{" Key #tag1 = #tag2 =", "Key", map[string]string{"tag1": "", "tag2": ""}},
+		{" some.suffix #tag1 = 2 # tag2", "some.suffix", map[string]string{"tag1": "2", "tag2": ""}},
+		{"#tag1", "", map[string]string{"tag1": ""}},
+		{"=tag1", "=tag1", map[string]string{}},
+		{`db #dsn="user:p#ss@tcp(host)/db?a=b"`, "db", map[string]string{"dsn": "user:p#ss@tcp(host)/db?a=b"}},
+		{`job #cron=0\ 0\ *\ *\ *`, "job", map[string]string{"cron": "0 0 * * *"}},
+		{`job #cron= "  0 0 * * * " `, "job", map[string]string{"cron": "  0 0 * * * "}},
+		{`"my #key" #"tag=name"=\#1`, "my #key", map[string]string{"tag=name": "#1"}},
+		{`key\=1 #a\=b=c=d`, "key=1", map[string]string{"a=b": "c=d"}},
+		{`key #say="\"hi\" \\ bye"`, "key", map[string]string{"say": `"hi" \ bye`}},
+		{`C:\dir #path=C:\dir\file`, `C:\dir`, map[string]string{"path": `C:\dir\file`}},
+		{`key #empty=""`, "key", map[string]string{"empty": ""}},
+		{`key\ `, "key ", map[string]string{}},
+	}
+
+	for _, data := range tests {
+		t.Run(data.raw, func(t *testing.T) {
+			key, tags, err := parseKey(data.raw)
+			assert.Nil(t, err)
+			assert.Equal(t, data.key, key)
+			assert.Equal(t, data.tags, tags)
+		})
+	}
+}
+
+// keyCase is a case of testdata/keys.json, shared with the tests of the key parser of the analysis module.
+type keyCase struct {
+	Raw  string              `json:"raw"`
+	Key  string              `json:"key"`
+	Tags map[string][]string `json:"tags"`
+}
+
+func TestParseKeyValues(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/keys.json")
+	assert.Nil(t, err)
+	var tests []keyCase
+	assert.Nil(t, json.Unmarshal(src, &tests))
+
+	for _, data := range tests {
+		t.Run(data.Raw, func(t *testing.T) {
+			key, tags, err := parseKeyValues(data.Raw)
+			assert.Nil(t, err)
+			assert.Equal(t, data.Key, key)
+			assert.Equal(t, data.Tags, tags)
+		})
+	}
+
+	t.Run("parseKey keeps first values", func(t *testing.T) {
+		_, tags, _ := parseKey(" Key #event=a #event=b")
+		assert.Equal(t, map[string]string{"event": "a"}, tags)
+	})
+}
+
+func TestParseKeyErrors(t *testing.T) {
+	tests := []struct {
+		raw string
+		err string
+	}{
+		{"key #=x", "empty tag name at position 5"},
+		{"key #", "empty tag name at position 5"},
+		{"key #a ## b", "empty tag name at position 8"},
+		{"key #  = x", "empty tag name at position 5"},
+		{`key #"" = x`, "empty tag name at position 5"},
+		{`key #a="x`, "unterminated quote at position 8"},
+		{`"key`, "unterminated quote at position 1"},
+		{`key #a="x\"`, "unterminated quote at position 8"},
+	}
+
+	for _, data := range tests {
+		t.Run(data.raw, func(t *testing.T) {
+			key, tags, err := parseKey(data.raw)
+			assert.Equal(t, "", key)
+			assert.Nil(t, tags)
+			assert.EqualError(t, err, "invalid key '"+data.raw+"': "+data.err)
+		})
+	}
+}
+
+func TestQuoteKeyPart(t *testing.T) {
+	for _, part := range []string{"plain", "with space", " padded ", "a#b", "a=b", `say "hi"`, `C:\dir`, "", "priority;x"} {
+		t.Run(part, func(t *testing.T) {
+			_, tags, err := parseKey("key #tag=" + quoteKeyPart(part))
+			assert.Nil(t, err)
+			assert.Equal(t, part, tags["tag"])
+		})
+	}
+
+	assert.Equal(t, "plain", quoteKeyPart("plain"))
+	assert.Equal(t, `"a#b"`, quoteKeyPart("a#b"))
+	assert.Equal(t, `"say \"hi\" \\"`, quoteKeyPart(`say "hi" \`))
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"strings"
+)
+
+// locatorPrefix is the prefix of the inject struct tags which inject a locator instead of a service, followed by the
+// comma separated list of keys or tags available in the locator, e.g. `inject:"locator:k1,k2,#tag"`.
+const locatorPrefix = "locator:"
+
+// locator is a Container implementation restricted to an explicit whitelist of services. Services can be whitelisted
+// by key or by tag, with an optional value, using the same syntax as in keys: "#tag" or "#tag=value". It keeps the
+// root container, so every retrieval builds its services on a new unsealed view and locators can be stored and used
+// concurrently. Views start from the dependencies graph being built by the container which created the locator, so
+// services reaching themselves through a locator are still detected as circular references.
+type locator struct {
+	container *container
+	parent    *container
+	keys      map[string]bool
+	tags      map[string][]string
+}
+
+// newLocator returns a locator wrapping the root of the given container and restricted to the given list of keys or
+// tags.
+func newLocator(c *container, entries []string) *locator {
+	l := &locator{
+		container: c.root,
+		parent:    c,
+		keys:      make(map[string]bool),
+		tags:      make(map[string][]string),
+	}
+
+	for _, e := range entries {
+		k, tags, err := parseKeyValues(e)
+		if err != nil {
+			panic(err.Error())
+		}
+		if k != "" {
+			l.keys[k] = true
+		}
+
+		for tag, vs := range tags {
+			for _, value := range vs {
+				if value == "" {
+					l.tags[tag] = []string{}
+					break
+				}
+				if values, ok := l.tags[tag]; !ok || len(values) > 0 {
+					l.tags[tag] = append(values, value)
+				}
+			}
+		}
+	}
+
+	return l
+}
+
+// parseLocatorEntries splits the comma separated list of entries of a locator inject tag.
+func parseLocatorEntries(raw string) []string {
+	entries := make([]string, 0)
+	for _, e := range strings.Split(strings.TrimPrefix(raw, locatorPrefix), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+// allows returns if a service on the given key is available in the locator.
+func (l *locator) allows(key string) bool {
+	if l.keys[key] {
+		return true
+	}
+
+	def := l.container.builder.GetDefinition(key)
+	if def == nil {
+		return false
+	}
+
+	for tag, values := range l.tags {
+		if !def.HasTag(tag) {
+			continue
+		}
+
+		if len(values) == 0 {
+			return true
+		}
+
+		for _, value := range values {
+			if def.HasTagValue(tag, value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Get retrieves a service from the underlying container. It panics if the service is not available in the locator.
+func (l *locator) Get(key string) interface{} {
+	if !l.allows(key) {
+		panic(fmt.Sprintf("service with key '%s' is not available in the locator", key))
+	}
+
+	return l.view().Get(key)
+}
+
+// view returns a new unsealed view of the root container starting from the current dependencies graph of the
+// container which created the locator.
+func (l *locator) view() *container {
+	l.parent.lock.Lock()
+	defer l.parent.lock.Unlock()
+
+	return l.container.view(l.parent.loading)
+}
+
+// GetTaggedBy returns the services related to a given tag, like the container does, but only the ones available in the
+// locator.
+func (l *locator) GetTaggedBy(tag string, values ...string) []interface{} {
+	keys := l.container.builder.GetTaggedKeys(tag, values)
+	services := make([]interface{}, 0, len(keys))
+	v := l.view()
+	for _, key := range keys {
+		if l.allows(key) {
+			services = append(services, v.Get(key))
+		}
+	}
+
+	return services
+}
+
+// SetLocator adds a factory definition on a given key which builds a Container restricted to the given list of keys or
+// tags, using the "#tag" or "#tag=value" syntax for the latter. Requesting any other service from the locator panics,
+// so dependencies of the services receiving it, like plugins, are explicit:
+//
+//	b.SetLocator("plugins.locator", "logger", "#plugin")
+//
+// Locators can also be injected into struct fields with an inject tag using the "locator:" prefix:
+//
+//	type Manager struct {
+//		Plugins Container `inject:"locator:logger,#plugin"`
+//	}
+//
+// It panics if some entry is malformed, see parseKey.
+func (c *containerBuilder) SetLocator(key string, entries ...string) DefinitionBuilder {
+	for _, e := range entries {
+		if _, _, err := parseKeyValues(e); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	d := c.setDefinition(key, func(c Container) interface{} {
+		return newLocator(c.(*container), entries)
+	}, map[string]string{TagFactory: ""})
+	d.Locator = entries
+
+	return c.configure(d)
+}
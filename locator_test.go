@@ -0,0 +1,151 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerBuilder_SetLocator(t *testing.T) {
+	newBuilder := func() *containerBuilder {
+		b := newContainerBuilder()
+		b.SetValue("logger #private", "logger")
+		b.SetValue("secret", "secret")
+		b.SetValue("plugin.a #plugin=a #priority=1", "a")
+		b.SetValue("plugin.b #plugin=b #priority=2", "b")
+		b.SetValue("plugin.c #plugin=c #priority=3", "c")
+		return b
+	}
+
+	t.Run("retrieves whitelisted services by key or tag", func(t *testing.T) {
+		b := newBuilder()
+		b.SetLocator("locator", "logger", "#plugin")
+		c := b.GetContainer()
+
+		l := c.Get("locator").(Container)
+		assert.Equal(t, []string{"logger", "#plugin"}, b.definitions["locator"].Locator)
+		assert.Equal(t, "logger", l.Get("logger"))
+		assert.Equal(t, "a", l.Get("plugin.a"))
+		assert.Equal(t, []interface{}{"c", "b", "a"}, l.GetTaggedBy("plugin"))
+	})
+
+	t.Run("retrieves whitelisted services by any tag value", func(t *testing.T) {
+		b := newBuilder()
+		b.SetValue("plugin.multi #plugin=x #plugin=b", "multi")
+		b.SetLocator("locator", "#plugin=a #plugin=b")
+		c := b.GetContainer()
+
+		l := c.Get("locator").(Container)
+		assert.Equal(t, []interface{}{"b", "a", "multi"}, l.GetTaggedBy("plugin"))
+		assert.Panics(t, func() {
+			l.Get("plugin.c")
+		})
+	})
+
+	t.Run("retrieves whitelisted services by tag value", func(t *testing.T) {
+		b := newBuilder()
+		b.SetLocator("locator", "#plugin=a", "#plugin=c")
+		c := b.GetContainer()
+
+		l := c.Get("locator").(Container)
+		assert.Equal(t, "a", l.Get("plugin.a"))
+		assert.Equal(t, []interface{}{"c", "a"}, l.GetTaggedBy("plugin"))
+		assert.Panics(t, func() {
+			l.Get("plugin.b")
+		})
+	})
+
+	t.Run("panics if service is not whitelisted", func(t *testing.T) {
+		b := newBuilder()
+		b.SetLocator("locator", "logger")
+		c := b.GetContainer()
+
+		l := c.Get("locator").(Container)
+		assert.PanicsWithValue(t, "service with key 'secret' is not available in the locator", func() {
+			l.Get("secret")
+		})
+		assert.PanicsWithValue(t, "service with key 'none' is not available in the locator", func() {
+			l.Get("none")
+		})
+	})
+
+	t.Run("injects locators into struct fields", func(t *testing.T) {
+		type Manager struct {
+			Plugins Container `inject:"locator: logger, #plugin=b"`
+		}
+
+		b := newBuilder()
+		b.SetInjectable("manager", Manager{})
+		c := b.GetContainer()
+
+		m := c.Get("manager").(Manager)
+		assert.Equal(t, "logger", m.Plugins.Get("logger"))
+		assert.Equal(t, []interface{}{"b"}, m.Plugins.GetTaggedBy("plugin"))
+		assert.Panics(t, func() {
+			m.Plugins.Get("secret")
+		})
+	})
+
+	t.Run("retrieves services concurrently from a stored locator", func(t *testing.T) {
+		type Manager struct {
+			L Container `inject:"locator:plugin,#plugin"`
+		}
+
+		b := newBuilder()
+		b.SetFactory("plugin", func(c Container) interface{} {
+			return c.Get("logger").(string) + ".plugin"
+		})
+		b.SetInjectable("manager #shared", &Manager{})
+		c := b.GetContainer()
+		m := c.Get("manager").(*Manager)
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					assert.Equal(t, "logger.plugin", m.L.Get("plugin"))
+					assert.Len(t, m.L.GetTaggedBy("plugin"), 3)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("panics on circular references through a locator", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetLocator("locator", "a")
+		b.SetFactory("a #shared", func(c Container) interface{} {
+			return c.Get("locator").(Container).Get("a")
+		})
+		c := b.GetContainer()
+
+		assert.PanicsWithValue(t, "circular reference found while building service 'a' at service 'a'", func() {
+			c.Get("a")
+		})
+	})
+
+	t.Run("panics if locator field is not a container", func(t *testing.T) {
+		type Manager struct {
+			Plugins string `inject:"locator:logger"`
+		}
+
+		b := newBuilder()
+		assert.PanicsWithValue(t, "locator can not be injected in field Manager: Plugins of type string", func() {
+			b.SetInjectable("manager", Manager{})
+		})
+	})
+
+	t.Run("panics on malformed entries", func(t *testing.T) {
+		b := newBuilder()
+		assert.PanicsWithValue(t, "invalid key '#plugin=\"a': unterminated quote at position 9", func() {
+			b.SetLocator("locator", "logger", `#plugin="a`)
+		})
+	})
+}
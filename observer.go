@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Observer is notified by the container about the construction of services, to trace or measure them. OnBeforeBuild
+// receives the chain of services being built, the last one being the given key. OnAfterBuild receives the duration of
+// the build, including its dependencies, and the error if the build panicked. OnCacheHit is called when a shared
+// service is returned from the container without building it.
+type Observer interface {
+	OnBeforeBuild(key string, chain []string)
+	OnAfterBuild(key string, instance interface{}, duration time.Duration, err error)
+	OnCacheHit(key string)
+}
+
+// observers are the observers added to a container, shared between the container and its unsealed copies.
+type observers struct {
+	list []Observer
+	lock sync.RWMutex
+}
+
+// AddObserver adds observers to be notified about the construction of services of current container. Observers should be
+// added before retrieving any service.
+func (c *container) AddObserver(os ...Observer) {
+	c.observers.lock.Lock()
+	defer c.observers.lock.Unlock()
+
+	c.observers.list = append(c.observers.list, os...)
+}
+
+// notified returns the observers to notify about the construction of services of current container: the ones added to
+// the container and the ones scoped to current copy of it, see scope.
+func (c *container) notified() []Observer {
+	c.observers.lock.RLock()
+	defer c.observers.lock.RUnlock()
+
+	if len(c.scoped) == 0 {
+		return c.observers.list
+	}
+
+	return append(append(make([]Observer, 0, len(c.observers.list)+len(c.scoped)), c.observers.list...), c.scoped...)
+}
+
+// scope returns a copy of current container which also notifies the given observers, so they only observe the services
+// built from the copy or its unsealed copies, and not the ones retrieved concurrently from current container.
+func (c *container) scope(os ...Observer) *container {
+	sc := *c
+	sc.lock = &sync.Mutex{}
+	sc.loading = append(make([]string, 0, cap(c.loading)), c.loading...)
+	sc.scoped = append(append([]Observer(nil), c.scoped...), os...)
+
+	return &sc
+}
+
+// expvarObserver is an Observer which publishes construction counters and timings as expvar variables.
+type expvarObserver struct {
+	vars *expvar.Map
+}
+
+// NewExpvarObserver returns an Observer which adds, for each service key, the following counters to the given map:
+// "<key>.builds", "<key>.build_ns" with the total construction time in nanoseconds, "<key>.errors" and "<key>.hits"
+// for the shared instances returned from cache. Use expvar.NewMap to publish it:
+//
+//	c.AddObserver(di.NewExpvarObserver(expvar.NewMap("di")))
+func NewExpvarObserver(vars *expvar.Map) Observer {
+	return &expvarObserver{vars: vars}
+}
+
+// OnBeforeBuild does nothing, builds are counted once finished.
+func (o *expvarObserver) OnBeforeBuild(string, []string) {}
+
+// OnAfterBuild counts the build, its duration and its error if any.
+func (o *expvarObserver) OnAfterBuild(key string, _ interface{}, duration time.Duration, err error) {
+	o.vars.Add(key+".builds", 1)
+	o.vars.Add(key+".build_ns", duration.Nanoseconds())
+	if err != nil {
+		o.vars.Add(key+".errors", 1)
+	}
+}
+
+// OnCacheHit counts the hit.
+func (o *expvarObserver) OnCacheHit(key string) {
+	o.vars.Add(key+".hits", 1)
+}
+
+// LogObserver adapts a structured logging func into an Observer. The func receives an event name, one of
+// "di.build.start", "di.build.end" or "di.cache.hit", and its fields: "key", "chain", "duration" and "error".
+type LogObserver func(event string, fields map[string]interface{})
+
+// OnBeforeBuild logs the "di.build.start" event.
+func (f LogObserver) OnBeforeBuild(key string, chain []string) {
+	f("di.build.start", map[string]interface{}{"key": key, "chain": chain})
+}
+
+// OnAfterBuild logs the "di.build.end" event.
+func (f LogObserver) OnAfterBuild(key string, _ interface{}, duration time.Duration, err error) {
+	fields := map[string]interface{}{"key": key, "duration": duration}
+	if err != nil {
+		fields["error"] = err
+	}
+	f("di.build.end", fields)
+}
+
+// OnCacheHit logs the "di.cache.hit" event.
+func (f LogObserver) OnCacheHit(key string) {
+	f("di.cache.hit", map[string]interface{}{"key": key})
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingObserver struct {
+	events []string
+}
+
+func (o *recordingObserver) OnBeforeBuild(key string, chain []string) {
+	o.events = append(o.events, fmt.Sprintf("before %s %v", key, chain))
+}
+
+func (o *recordingObserver) OnAfterBuild(key string, instance interface{}, _ time.Duration, err error) {
+	o.events = append(o.events, fmt.Sprintf("after %s %v %v", key, instance, err))
+}
+
+func (o *recordingObserver) OnCacheHit(key string) {
+	o.events = append(o.events, fmt.Sprintf("hit %s", key))
+}
+
+func newObservedContainer() *container {
+	b := newContainerBuilder()
+	b.SetValue("val #shared #private", 1)
+	b.SetFactory("one", func(c Container) interface{} { return c.Get("val").(int) })
+	b.SetFactory("bad", func(c Container) interface{} { return c.Get("one").(string) })
+
+	return b.resolve()
+}
+
+func TestContainer_AddObserver(t *testing.T) {
+	t.Run("notifies builds and cache hits", func(t *testing.T) {
+		o := &recordingObserver{}
+		c := newObservedContainer()
+		c.AddObserver(o)
+
+		c.Get("one")
+		c.Get("one")
+
+		assert.Equal(t, []string{
+			"before one [one]",
+			"before val [one val]",
+			"after val 1 <nil>",
+			"after one 1 <nil>",
+			"before one [one]",
+			"hit val",
+			"after one 1 <nil>",
+		}, o.events)
+	})
+
+	t.Run("notifies build errors", func(t *testing.T) {
+		o := &recordingObserver{}
+		c := newObservedContainer()
+		c.AddObserver(o)
+
+		assert.Panics(t, func() {
+			c.Get("bad")
+		})
+		assert.Equal(t, "after bad <nil> interface conversion: interface {} is int, not string", o.events[len(o.events)-1])
+	})
+
+	t.Run("notifies scoped observers only from the scoped copy", func(t *testing.T) {
+		o := &recordingObserver{}
+		c := newObservedContainer()
+		sc := c.scope(o)
+
+		c.Get("one")
+		assert.Empty(t, o.events)
+
+		sc.Get("one")
+		assert.Equal(t, "after one 1 <nil>", o.events[len(o.events)-1])
+		assert.Empty(t, c.notified())
+	})
+
+	t.Run("adds observers while retrieving services concurrently", func(t *testing.T) {
+		vars := new(expvar.Map).Init()
+		c := newObservedContainer()
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				c.AddObserver(NewExpvarObserver(vars))
+			}()
+			go func() {
+				defer wg.Done()
+				assert.Equal(t, 1, c.Get("one"))
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, c.notified(), 4)
+	})
+}
+
+func TestNewExpvarObserver(t *testing.T) {
+	vars := new(expvar.Map).Init()
+	c := newObservedContainer()
+	c.AddObserver(NewExpvarObserver(vars))
+
+	c.Get("one")
+	c.Get("one")
+	assert.Panics(t, func() {
+		c.Get("bad")
+	})
+
+	assert.Equal(t, "3", vars.Get("one.builds").String())
+	assert.Equal(t, "1", vars.Get("val.builds").String())
+	assert.Equal(t, "2", vars.Get("val.hits").String())
+	assert.Equal(t, "1", vars.Get("bad.errors").String())
+	assert.Nil(t, vars.Get("one.errors"))
+	assert.NotNil(t, vars.Get("one.build_ns"))
+}
+
+func TestLogObserver(t *testing.T) {
+	events := make([]string, 0)
+	fields := make([]map[string]interface{}, 0)
+	o := LogObserver(func(event string, f map[string]interface{}) {
+		events = append(events, event)
+		fields = append(fields, f)
+	})
+
+	err := errors.New("failed")
+	o.OnBeforeBuild("k", []string{"p", "k"})
+	o.OnAfterBuild("k", 1, time.Second, err)
+	o.OnCacheHit("k")
+
+	assert.Equal(t, []string{"di.build.start", "di.build.end", "di.cache.hit"}, events)
+	assert.Equal(t, []map[string]interface{}{
+		{"key": "k", "chain": []string{"p", "k"}},
+		{"key": "k", "duration": time.Second, "error": err},
+		{"key": "k"},
+	}, fields)
+}
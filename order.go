@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import "sort"
+
+// TaggedService describes a tagged service being sorted. Priority is the one of the matching tag values, if they have a
+// priority attribute, and Order the position in which its key was registered.
+type TaggedService struct {
+	Key      string
+	Priority int16
+	Tags     map[string]string
+	Order    int
+}
+
+// TagComparator compares two tagged services to sort the lists of tagged services. It returns a negative number if the
+// service a goes first, a positive number if the service b goes first, or zero if they are equivalent, in which case
+// services are sorted by registration order and then by key.
+type TagComparator func(a, b TaggedService) int
+
+// PriorityDesc sorts tagged services from the highest priority to the lowest one. It is the default order.
+func PriorityDesc(a, b TaggedService) int {
+	return int(b.Priority) - int(a.Priority)
+}
+
+// PriorityAsc sorts tagged services from the lowest priority to the highest one.
+func PriorityAsc(a, b TaggedService) int {
+	return int(a.Priority) - int(b.Priority)
+}
+
+// SetTagComparator replaces the comparator used to sort the services returned by GetTaggedKeys, GetTaggedBy and the
+// queries. Services which are equivalent for the comparator are sorted by registration order, where overwritten
+// definitions keep the position of the first definition on their key, and then by key. By default, PriorityDesc is used.
+func (c *containerBuilder) SetTagComparator(cmp TagComparator) {
+	c.panicIfResolved()
+	c.tagComparator = cmp
+}
+
+// sortTagged sorts the given keys of services retrieved by the given tag and values with the tag comparator, by
+// registration order and then by key. The priority of each service is the one of the matching tag values, if any.
+func (c *containerBuilder) sortTagged(keys []string, tag string, values []string) {
+	cmp := c.tagComparator
+	if cmp == nil {
+		cmp = PriorityDesc
+	}
+
+	services := make(map[string]TaggedService, len(keys))
+	for _, k := range keys {
+		def := c.definitions[k]
+		services[k] = TaggedService{Key: k, Priority: def.taggedPriority(tag, values), Tags: def.Tags, Order: c.order[k]}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := services[keys[i]], services[keys[j]]
+		if r := cmp(a, b); r != 0 {
+			return r < 0
+		}
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return a.Key < b.Key
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerBuilder_SetTagComparator(t *testing.T) {
+	newBuilder := func() *containerBuilder {
+		b := newContainerBuilder()
+		b.SetValue("m.zeta #middleware", "zeta")
+		b.SetValue("m.alpha #middleware #priority=5", "alpha")
+		b.SetValue("m.beta #middleware", "beta")
+		b.SetValue("m.gamma #middleware #priority=-1", "gamma")
+		b.SetValue("m.delta #middleware", "delta")
+
+		return b
+	}
+
+	t.Run("sorts equal priorities by registration order", func(t *testing.T) {
+		b := newBuilder()
+
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, []string{"m.alpha", "m.zeta", "m.beta", "m.delta", "m.gamma"},
+				b.GetTaggedKeys("middleware", nil))
+		}
+	})
+
+	t.Run("keeps the registration order of overwritten definitions", func(t *testing.T) {
+		b := newBuilder()
+		b.SetValue("m.zeta #middleware", "zeta2")
+
+		assert.Equal(t, []interface{}{"alpha", "zeta2", "beta", "delta", "gamma"},
+			b.GetContainer().GetTaggedBy("middleware"))
+	})
+
+	t.Run("sorts by ascending priority", func(t *testing.T) {
+		b := newBuilder()
+		b.SetTagComparator(PriorityAsc)
+
+		assert.Equal(t, []string{"m.gamma", "m.zeta", "m.beta", "m.delta", "m.alpha"},
+			b.GetTaggedKeys("middleware", nil))
+	})
+
+	t.Run("sorts with custom comparators", func(t *testing.T) {
+		b := newBuilder()
+		b.SetValue("m.beta #middleware #name=b", "beta")
+		b.SetValue("m.zeta #middleware #name=a", "zeta")
+		b.SetTagComparator(func(a, b TaggedService) int {
+			return strings.Compare(a.Tags["name"], b.Tags["name"])
+		})
+
+		keys, err := b.QueryKeys("middleware")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"m.alpha", "m.gamma", "m.delta", "m.zeta", "m.beta"}, keys)
+	})
+
+	t.Run("sorts by key when comparator and registration order are equal", func(t *testing.T) {
+		b := newBuilder()
+		b.order = map[string]int{}
+
+		assert.Equal(t, []string{"m.alpha", "m.beta", "m.delta", "m.zeta", "m.gamma"},
+			b.GetTaggedKeys("middleware", nil))
+	})
+
+	t.Run("panics if resolved", func(t *testing.T) {
+		b := newBuilder()
+		b.GetContainer()
+
+		assert.Panics(t, func() {
+			b.SetTagComparator(PriorityAsc)
+		})
+	})
+}
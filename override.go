@@ -0,0 +1,151 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// overrides holds the definitions replacing the builder ones on a container, without altering the builder, which may
+// be shared by other containers. It is shared between a container and its unsealed copies.
+type overrides struct {
+	defs map[string]*definition
+	lock sync.RWMutex
+}
+
+// definition returns the definition of the service on the given key, the overriding one if any.
+func (c *container) definition(key string) *definition {
+	c.overrides.lock.RLock()
+	def, ok := c.overrides.defs[key]
+	c.overrides.lock.RUnlock()
+
+	if ok {
+		return def
+	}
+
+	return c.builder.definitions[key]
+}
+
+// Override replaces the service on the given key of current container with the given value, keeping the tags of the
+// replaced definition except the kind and shared ones, and removes the built instances of the shared services depending
+// on it so they get the new value. The aliases of the service are replaced as well, so the service is also overridden
+// when retrieved through them. The builder definitions are not altered. It returns a function to restore the previous
+// services, meant to be deferred or registered as a test cleanup. It panics if there is no service on the given key.
+func (c *container) Override(key string, value interface{}) (restore func()) {
+	if c.definition(key) == nil {
+		panic(fmt.Sprintf("definition with id '%s' does not exist and cannot be overridden", key))
+	}
+
+	keys := append([]string{key}, c.aliases(key)...)
+	defs := make(map[string]*definition, len(keys))
+	for _, k := range keys {
+		defs[k] = overriding(c.definition(k), k, value)
+	}
+
+	c.overrides.lock.Lock()
+	olds := make(map[string]*definition, len(keys))
+	for k, def := range defs {
+		if old, ok := c.overrides.defs[k]; ok {
+			olds[k] = old
+		}
+		c.overrides.defs[k] = def
+	}
+	c.overrides.lock.Unlock()
+	c.invalidate(keys...)
+
+	return func() {
+		c.overrides.lock.Lock()
+		for _, k := range keys {
+			if old, ok := olds[k]; ok {
+				c.overrides.defs[k] = old
+			} else {
+				delete(c.overrides.defs, k)
+			}
+		}
+		c.overrides.lock.Unlock()
+		c.invalidate(keys...)
+	}
+}
+
+// aliases returns the sorted keys of the aliases, direct or transitive, of the builder definition on the given key.
+func (c *container) aliases(key string) []string {
+	target, ok := c.builder.definitions[key]
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0)
+	for k, d := range c.builder.definitions {
+		for a := d.AliasOf; a != nil; a = a.AliasOf {
+			if a == target {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// overriding returns the value definition replacing the given one on the given key, with its tags except the kind and
+// shared ones.
+func overriding(prev *definition, key string, value interface{}) *definition {
+	tags := make(map[string]string, len(prev.Tags))
+	for t, v := range prev.Tags {
+		tags[t] = v
+	}
+	for _, t := range kindTags {
+		delete(tags, t)
+	}
+	delete(tags, TagShared)
+	tags[TagValue] = ""
+
+	def, err := newDefinition(prev.schemas, func(Container) interface{} {
+		return value
+	}, tags)
+	if err != nil {
+		panic(fmt.Sprintf("%s for key '%s'", err, key))
+	}
+	for t := range tags {
+		if t != TagValue {
+			def.copyTag(prev, t)
+		}
+	}
+
+	return def
+}
+
+// invalidate removes the built instances of the shared services on the given keys and the ones depending on them,
+// directly or transitively, according to the dependency graph.
+func (c *container) invalidate(keys ...string) {
+	dependents := make(map[string][]string)
+	for _, e := range c.Graph().Edges {
+		dependents[e.To] = append(dependents[e.To], e.From)
+	}
+
+	visited := make(map[string]bool)
+	stack := append([]string(nil), keys...)
+	for len(stack) > 0 {
+		k := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[k] {
+			continue
+		}
+		visited[k] = true
+		stack = append(stack, dependents[k]...)
+	}
+
+	c.singletons.lock.Lock()
+	defer c.singletons.lock.Unlock()
+
+	for k := range visited {
+		delete(c.instances, k)
+	}
+
+	c.singletons.flush()
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainer_Override(t *testing.T) {
+	newContainer := func() *container {
+		b := newContainerBuilder()
+		b.SetValue("sender #private", "smtp")
+		b.SetFactory("transport #shared", func(c Container) interface{} {
+			s := c.Get("sender").(string)
+			return &s
+		})
+		b.SetFactory("mailer #shared", func(c Container) interface{} {
+			return []string{*c.Get("transport").(*string)}
+		})
+		b.SetValue("other #shared", "other")
+
+		return b.resolve()
+	}
+
+	t.Run("panics if service does not exist", func(t *testing.T) {
+		c := newContainer()
+
+		assert.PanicsWithValue(t, "definition with id 'none' does not exist and cannot be overridden", func() {
+			c.Override("none", 1)
+		})
+	})
+
+	t.Run("keeps tags of the replaced service", func(t *testing.T) {
+		c := newContainer()
+
+		c.Override("sender", "fake")
+
+		assert.Panics(t, func() {
+			c.Get("sender")
+		})
+		assert.Equal(t, "fake", c.overrides.defs["sender"].Factory(c))
+		assert.Equal(t, TagValue, c.overrides.defs["sender"].Kind)
+		assert.Equal(t, "smtp", c.builder.definitions["sender"].Factory(c))
+	})
+
+	t.Run("removes instances of dependent services", func(t *testing.T) {
+		c := newContainer()
+		assert.Equal(t, []string{"smtp"}, c.Get("mailer"))
+		_ = c.Get("other")
+
+		c.Override("sender", "fake")
+
+		assert.Len(t, c.instances, 1)
+		assert.Equal(t, []string{"fake"}, c.Get("mailer"))
+	})
+
+	t.Run("restores previous services", func(t *testing.T) {
+		c := newContainer()
+
+		restore1 := c.Override("sender", "fake1")
+		restore2 := c.Override("sender", "fake2")
+		assert.Equal(t, []string{"fake2"}, c.Get("mailer"))
+
+		restore2()
+		assert.Equal(t, []string{"fake1"}, c.Get("mailer"))
+
+		restore1()
+		assert.Equal(t, []string{"smtp"}, c.Get("mailer"))
+		assert.Empty(t, c.overrides.defs)
+	})
+
+	t.Run("overrides aliases of the service", func(t *testing.T) {
+		b := newContainerBuilder()
+		b.SetValue("mailer.smtp", "smtp")
+		b.SetAlias("mailer #shared", "mailer.smtp")
+		b.SetAlias("mailer.default #private", "mailer")
+		b.SetFactory("notifier", func(c Container) interface{} {
+			return c.Get("mailer.default")
+		})
+		c := b.resolve()
+		assert.Equal(t, "smtp", c.Get("mailer"))
+
+		restore := c.Override("mailer.smtp", "fake")
+
+		assert.Equal(t, "fake", c.Get("mailer.smtp"))
+		assert.Equal(t, "fake", c.Get("mailer"))
+		assert.Equal(t, "fake", c.Get("notifier"))
+		assert.True(t, c.overrides.defs["mailer.default"].Private)
+
+		restore()
+
+		assert.Equal(t, "smtp", c.Get("mailer"))
+		assert.Equal(t, "smtp", c.Get("notifier"))
+		assert.Empty(t, c.overrides.defs)
+	})
+
+	t.Run("does not alter other containers", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("sender", "smtp")
+		c1 := b.GetContainer()
+		c2 := b.GetContainer()
+
+		c1.Override("sender", "fake")
+
+		assert.Equal(t, "fake", c1.Get("sender"))
+		assert.Equal(t, "smtp", c2.Get("sender"))
+	})
+}
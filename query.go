@@ -0,0 +1,336 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// query is a boolean expression over the tags of a definition.
+type query interface {
+	match(def *definition) bool
+}
+
+// queryAnd matches if both operands match.
+type queryAnd struct {
+	left, right query
+}
+
+func (q queryAnd) match(def *definition) bool {
+	return q.left.match(def) && q.right.match(def)
+}
+
+// queryOr matches if any operand matches.
+type queryOr struct {
+	left, right query
+}
+
+func (q queryOr) match(def *definition) bool {
+	return q.left.match(def) || q.right.match(def)
+}
+
+// queryNot matches if the operand doesn't match.
+type queryNot struct {
+	operand query
+}
+
+func (q queryNot) match(def *definition) bool {
+	return !q.operand.match(def)
+}
+
+// queryTag matches if the tag is present and, if an operator is given, any of its values compares with the given one,
+// except for "!=", which matches if none of them is equal. The "=" and "!=" operators compare strings, while the rest
+// compare numbers and don't match non numeric values.
+type queryTag struct {
+	tag, op, value string
+}
+
+func (q queryTag) match(def *definition) bool {
+	values := def.GetTagValues(q.tag)
+	if values == nil {
+		return false
+	}
+
+	switch q.op {
+	case "":
+		return true
+	case "!=":
+		return !inStrings(q.value, values)
+	}
+
+	for _, v := range values {
+		if q.compare(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare returns if the given tag value compares with the query value.
+func (q queryTag) compare(v string) bool {
+	if q.op == "=" || q.op == "==" {
+		return v == q.value
+	}
+
+	a, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return false
+	}
+	b, _ := strconv.ParseFloat(q.value, 64)
+
+	switch q.op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	}
+	return a <= b
+}
+
+// queryToken is a token of a query with its position, starting at 1.
+type queryToken struct {
+	text   string
+	quoted bool
+	pos    int
+}
+
+// queryParser parses queries with the following grammar, where tags and values are sequences of characters other than
+// spaces, parentheses and operators, and values can also be quoted with double quotes:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | primary
+//	primary = "(" or ")" | tag [ ( "=" | "==" | "!=" | ">" | ">=" | "<" | "<=" ) value ]
+type queryParser struct {
+	tokens []queryToken
+	next   int
+}
+
+// queryOperators are the operators of the query language, the longest ones first.
+var queryOperators = []string{"&&", "||", "==", "!=", ">=", "<=", "!", "=", ">", "<", "(", ")"}
+
+// parseQuery parses the given query, returning an error with the position of the unexpected token if it's invalid.
+func parseQuery(raw string) (query, error) {
+	tokens, err := tokenizeQuery(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+	q, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected '%s' at position %d", t.text, t.pos)
+	}
+
+	return q, nil
+}
+
+// tokenizeQuery splits the given query into operators, tags, values and quoted values.
+func tokenizeQuery(raw string) ([]queryToken, error) {
+	tokens := make([]queryToken, 0)
+	runes := []rune(raw)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		if runes[i] == '"' {
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated quoted value at position %d", i+1)
+			}
+			tokens = append(tokens, queryToken{text: sb.String(), quoted: true, pos: i + 1})
+			i = j + 1
+			continue
+		}
+
+		if op := queryOperator(runes[i:]); op != "" {
+			tokens = append(tokens, queryToken{text: op, pos: i + 1})
+			i += len(op)
+			continue
+		}
+
+		j := i
+		for j < len(runes) && !unicode.IsSpace(runes[j]) && runes[j] != '"' && queryOperator(runes[j:]) == "" {
+			j++
+		}
+		tokens = append(tokens, queryToken{text: string(runes[i:j]), pos: i + 1})
+		i = j
+	}
+
+	return tokens, nil
+}
+
+// queryOperator returns the operator at the start of the given runes, if any.
+func queryOperator(runes []rune) string {
+	if len(runes) > 2 {
+		runes = runes[:2]
+	}
+
+	for _, op := range queryOperators {
+		if strings.HasPrefix(string(runes), op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// peek returns the next token without consuming it.
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.next < len(p.tokens) {
+		return p.tokens[p.next], true
+	}
+	return queryToken{}, false
+}
+
+// accept consumes the next token if it's the given operator.
+func (p *queryParser) accept(op string) bool {
+	if t, ok := p.peek(); ok && !t.quoted && t.text == op {
+		p.next++
+		return true
+	}
+	return false
+}
+
+// unexpected returns the error for the next token, or for the end of the query.
+func (p *queryParser) unexpected(expected string) error {
+	if t, ok := p.peek(); ok {
+		return fmt.Errorf("expected %s but found '%s' at position %d", expected, t.text, t.pos)
+	}
+	return fmt.Errorf("expected %s but found end of query", expected)
+}
+
+func (p *queryParser) or() (query, error) {
+	q, err := p.and()
+	for err == nil && p.accept("||") {
+		var right query
+		right, err = p.and()
+		q = queryOr{left: q, right: right}
+	}
+	return q, err
+}
+
+func (p *queryParser) and() (query, error) {
+	q, err := p.unary()
+	for err == nil && p.accept("&&") {
+		var right query
+		right, err = p.unary()
+		q = queryAnd{left: q, right: right}
+	}
+	return q, err
+}
+
+func (p *queryParser) unary() (query, error) {
+	if p.accept("!") {
+		q, err := p.unary()
+		return queryNot{operand: q}, err
+	}
+	return p.primary()
+}
+
+func (p *queryParser) primary() (query, error) {
+	if p.accept("(") {
+		q, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.unexpected("')'")
+		}
+		return q, nil
+	}
+
+	t, ok := p.peek()
+	if !ok || t.quoted || queryOperator([]rune(t.text)) != "" {
+		return nil, p.unexpected("tag")
+	}
+	p.next++
+
+	q := queryTag{tag: t.text}
+	for _, op := range []string{"==", "!=", ">=", "<=", "=", ">", "<"} {
+		if !p.accept(op) {
+			continue
+		}
+
+		v, ok := p.peek()
+		if !ok || !v.quoted && queryOperator([]rune(v.text)) != "" {
+			return nil, p.unexpected("value")
+		}
+		p.next++
+
+		if op != "=" && op != "==" && op != "!=" {
+			if _, err := strconv.ParseFloat(v.text, 64); err != nil {
+				return nil, fmt.Errorf("expected number but found '%s' at position %d", v.text, v.pos)
+			}
+		}
+		q.op, q.value = op, v.text
+		break
+	}
+
+	return q, nil
+}
+
+// QueryKeys returns the keys of the non abstract definitions whose tags match the given query, sorted as
+// GetTaggedKeys does. Queries combine tag presence tests ("tag"), value equality ("tag=value", "tag!=value") and numeric
+// comparisons ("tag>=2") with the "&&", "||" and "!" operators and parentheses:
+//
+//	event.listener && !deprecated && (channel=email || channel=sms) && version>=2
+//
+// Values containing spaces or operators can be quoted, e.g. tag="a b". It returns an error if the query is invalid.
+func (c *containerBuilder) QueryKeys(raw string) ([]string, error) {
+	q, err := parseQuery(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query '%s': %s", raw, err)
+	}
+
+	keys := make([]string, 0)
+	for key, def := range c.definitions {
+		if !def.Abstract && q.match(def) {
+			keys = append(keys, key)
+		}
+	}
+
+	c.sortTagged(keys, "", nil)
+
+	return keys, nil
+}
+
+// QueryKeys returns the keys of the services whose tags match the given query, see containerBuilder.QueryKeys.
+func (c *container) QueryKeys(raw string) ([]string, error) {
+	return c.builder.QueryKeys(raw)
+}
+
+// Query returns the services whose tags match the given query sorted by priority, see containerBuilder.QueryKeys. It
+// returns an error if the query is invalid, and panics as Get does if any matching service can't be retrieved.
+func (c *container) Query(raw string) ([]interface{}, error) {
+	keys, err := c.builder.QueryKeys(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		services = append(services, c.Get(key))
+	}
+
+	return services, nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newQueryBuilder() *containerBuilder {
+	b := newContainerBuilder()
+	b.SetValue("email.v1 #event.listener #channel=email #version=1", "email.v1")
+	b.SetValue("email.v2 #event.listener #channel=email #version=2 #priority=5", "email.v2")
+	b.SetValue("sms #event.listener #channel=sms #version=2.5", "sms")
+	b.SetValue("push #event.listener #channel=push notifications #version=3", "push")
+	b.SetValue("old #event.listener #channel=sms #version=3 #deprecated=use sms", "old")
+	b.SetValue("abstract #abstract #event.listener #channel=sms", "abstract")
+	b.SetValue("other #version=latest", "other")
+
+	return b
+}
+
+func TestContainerBuilder_QueryKeys(t *testing.T) {
+	data := []struct {
+		query string
+		keys  []string
+	}{
+		{"event.listener", []string{"email.v2", "email.v1", "sms", "push", "old"}},
+		{"channel=email", []string{"email.v2", "email.v1"}},
+		{"channel==email", []string{"email.v2", "email.v1"}},
+		{"channel!=email", []string{"sms", "push", "old"}},
+		{"!channel", []string{"other"}},
+		{"!!channel && version>2", []string{"sms", "push", "old"}},
+		{"version>=2", []string{"email.v2", "sms", "push", "old"}},
+		{"version<2.5", []string{"email.v2", "email.v1"}},
+		{"version<=2.5 && version>1", []string{"email.v2", "sms"}},
+		{"event.listener && !deprecated && (channel=email || channel=sms)", []string{"email.v2", "email.v1", "sms"}},
+		{"event.listener && !deprecated && (channel=email || channel=sms) && version>=2", []string{"email.v2", "sms"}},
+		{"channel=sms || channel=email && version=1", []string{"email.v1", "sms", "old"}},
+		{"(channel=sms || channel=email) && version=1", []string{"email.v1"}},
+		{`channel="push notifications"`, []string{"push"}},
+		{`channel="a\"b"`, []string{}},
+		{"missing", []string{}},
+	}
+
+	for _, d := range data {
+		t.Run(d.query, func(t *testing.T) {
+			keys, err := newQueryBuilder().QueryKeys(d.query)
+
+			assert.Nil(t, err)
+			assert.Equal(t, d.keys, keys)
+		})
+	}
+}
+
+func TestContainerBuilder_QueryKeysErrors(t *testing.T) {
+	data := []struct {
+		query string
+		err   string
+	}{
+		{"", "expected tag but found end of query"},
+		{"a &&", "expected tag but found end of query"},
+		{"a && || b", "expected tag but found '||' at position 6"},
+		{"(a || b", "expected ')' but found end of query"},
+		{"a b", "unexpected 'b' at position 3"},
+		{"a)", "unexpected ')' at position 2"},
+		{"a=", "expected value but found end of query"},
+		{"a=)", "expected value but found ')' at position 3"},
+		{"a>=x", "expected number but found 'x' at position 4"},
+		{`a="b`, "unterminated quoted value at position 3"},
+		{`"a"=b`, "expected tag but found 'a' at position 1"},
+	}
+
+	for _, d := range data {
+		t.Run(d.query, func(t *testing.T) {
+			keys, err := newQueryBuilder().QueryKeys(d.query)
+
+			assert.Nil(t, keys)
+			assert.EqualError(t, err, "invalid query '"+d.query+"': "+d.err)
+		})
+	}
+}
+
+func TestContainer_Query(t *testing.T) {
+	t.Run("returns matching services sorted by priority", func(t *testing.T) {
+		c := newQueryBuilder().GetContainer()
+
+		services, err := c.Query("channel=email || channel=sms && !deprecated")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"email.v2", "email.v1", "sms"}, services)
+	})
+
+	t.Run("returns matching keys", func(t *testing.T) {
+		c := newQueryBuilder().GetContainer()
+
+		keys, err := c.QueryKeys("version>2.5")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"push", "old"}, keys)
+	})
+
+	t.Run("returns error on invalid queries", func(t *testing.T) {
+		c := newQueryBuilder().GetContainer()
+
+		services, err := c.Query("channel=")
+
+		assert.Nil(t, services)
+		assert.EqualError(t, err, "invalid query 'channel=': expected value but found end of query")
+	})
+
+	t.Run("matches any value of multi-valued tags", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetValue("multi #channel=email #channel=sms #version=1 #version=3", "multi")
+		b.SetValue("single #channel=email #version=2", "single")
+
+		for q, keys := range map[string][]string{
+			"channel=sms":                   {"multi"},
+			"channel=email":                 {"multi", "single"},
+			"channel!=sms":                  {"single"},
+			"version>2":                     {"multi"},
+			"version<2 && channel!=push":    {"multi"},
+			"channel=email && !channel=sms": {"single"},
+		} {
+			actual, err := b.QueryKeys(q)
+
+			assert.Nil(t, err)
+			assert.Equal(t, keys, actual, q)
+		}
+	})
+
+	t.Run("panics on private services", func(t *testing.T) {
+		b := newQueryBuilder()
+		b.SetValue("private #private #event.listener", 1)
+		c := b.GetContainer()
+
+		assert.Panics(t, func() {
+			_, _ = c.Query("event.listener")
+		})
+	})
+}
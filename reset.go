@@ -0,0 +1,61 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+// Snapshot is a copy of the built instances of the shared services of a container, taken with Snapshot, which can be
+// rolled back with Restore.
+type Snapshot struct {
+	instances map[string]interface{}
+}
+
+// Reset removes the built instances of the shared services on the given keys and of the services depending on them,
+// directly or transitively, so they are built again on next retrieval. Dependencies are found in the dependency
+// graph, see Graph. If no keys are given, all the built instances are removed.
+func (c *container) Reset(keys ...string) {
+	if len(keys) > 0 {
+		c.invalidate(keys...)
+		return
+	}
+
+	c.singletons.lock.Lock()
+	defer c.singletons.lock.Unlock()
+
+	for k := range c.instances {
+		delete(c.instances, k)
+	}
+
+	c.singletons.flush()
+}
+
+// Snapshot returns a copy of the built instances of the shared services of current container. Instances are not
+// copied deeply, so restoring a snapshot rolls back which instances are cached but not changes made to them.
+func (c *container) Snapshot() *Snapshot {
+	c.singletons.lock.Lock()
+	defer c.singletons.lock.Unlock()
+
+	s := &Snapshot{instances: make(map[string]interface{}, len(c.instances))}
+	for k, i := range c.instances {
+		s.instances[k] = i
+	}
+
+	return s
+}
+
+// Restore replaces the built instances of the shared services of current container by the ones in the given snapshot,
+// so services built after the snapshot was taken are built again on next retrieval. Overridden services are not
+// restored, see Override.
+func (c *container) Restore(s *Snapshot) {
+	c.singletons.lock.Lock()
+	defer c.singletons.lock.Unlock()
+
+	for k := range c.instances {
+		delete(c.instances, k)
+	}
+	for k, i := range s.instances {
+		c.instances[k] = i
+	}
+
+	c.singletons.flush()
+}
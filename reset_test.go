@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newResetContainer() *container {
+	type Mailer struct {
+		Transport *int `inject:"transport"`
+	}
+
+	b := newContainerBuilder()
+	b.SetFactory("transport #shared #private", func(c Container) interface{} {
+		return new(int)
+	})
+	b.SetInjectable("mailer #shared", &Mailer{})
+	b.SetFactory("newsletter #shared", func(c Container) interface{} {
+		return []interface{}{c.Get("mailer")}
+	})
+	b.SetFactory("logger #shared", func(c Container) interface{} {
+		return new(int)
+	})
+
+	return b.resolve()
+}
+
+func TestContainer_Reset(t *testing.T) {
+	t.Run("removes instances of given services and dependents", func(t *testing.T) {
+		c := newResetContainer()
+		_ = c.Get("newsletter")
+		logger := c.Get("logger")
+
+		c.Reset("transport")
+
+		assert.Len(t, c.instances, 1)
+		assert.Same(t, logger, c.Get("logger"))
+	})
+
+	t.Run("removes only dependents", func(t *testing.T) {
+		c := newResetContainer()
+		_ = c.Get("newsletter")
+
+		c.Reset("mailer")
+
+		assert.Contains(t, c.instances, "transport")
+		assert.NotContains(t, c.instances, "mailer")
+		assert.NotContains(t, c.instances, "newsletter")
+	})
+
+	t.Run("removes all instances without keys", func(t *testing.T) {
+		c := newResetContainer()
+		_ = c.Get("newsletter")
+		_ = c.Get("logger")
+
+		c.Reset()
+
+		assert.Empty(t, c.instances)
+	})
+}
+
+func TestContainer_SnapshotRestore(t *testing.T) {
+	c := newResetContainer()
+	logger := c.Get("logger")
+	s := c.Snapshot()
+
+	_ = c.Get("newsletter")
+	c.Reset("logger")
+	_ = c.Get("logger")
+	assert.NotSame(t, logger, c.Get("logger"))
+
+	c.Restore(s)
+
+	assert.Len(t, c.instances, 1)
+	assert.Same(t, logger, c.Get("logger"))
+}
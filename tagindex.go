@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tagIndex indexes the keys of the non abstract definitions of a resolved builder by tag name. It is built once in
+// GetContainer and never modified afterwards, so it can be read concurrently without locking.
+type tagIndex struct {
+	builder *containerBuilder
+	tags    map[string]*taggedKeys
+}
+
+// taggedKeys holds the sorted keys of the definitions with a tag, both all of them and grouped by tag value.
+type taggedKeys struct {
+	all    []string
+	values map[string][]string
+}
+
+// buildTagIndex returns the tag index of current definitions, with the keys sorted as GetTaggedKeys does.
+func (c *containerBuilder) buildTagIndex() *tagIndex {
+	index := &tagIndex{builder: c, tags: make(map[string]*taggedKeys)}
+	for key, def := range c.definitions {
+		if def.Abstract {
+			continue
+		}
+
+		for tag := range def.Tags {
+			t, ok := index.tags[tag]
+			if !ok {
+				t = &taggedKeys{values: make(map[string][]string)}
+				index.tags[tag] = t
+			}
+			t.all = append(t.all, key)
+			for _, v := range def.GetTagValues(tag) {
+				t.values[v] = append(t.values[v], key)
+			}
+		}
+	}
+
+	for tag, t := range index.tags {
+		c.sortTagged(t.all, tag, nil)
+		for v, keys := range t.values {
+			c.sortTagged(keys, tag, []string{v})
+		}
+	}
+
+	return index
+}
+
+// keys returns the sorted keys of the definitions with the given tag and, if provided, any of the given values. The
+// returned slice must not be modified.
+func (i *tagIndex) keys(tag string, values []string) []string {
+	t, ok := i.tags[tag]
+	if !ok {
+		return nil
+	}
+
+	switch len(values) {
+	case 0:
+		return t.all
+	case 1:
+		return t.values[values[0]]
+	}
+
+	keys := make([]string, 0)
+	for _, k := range t.all {
+		for _, v := range values {
+			if i.builder.definitions[k].HasTagValue(tag, v) {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	i.builder.sortTagged(keys, tag, values)
+
+	return keys
+}
+
+// taggedCacheKey returns the key of a list of tagged services in the cache of the singletons. The number of values is
+// included so no values and a single empty value don't share the key.
+func taggedCacheKey(tag string, values []string) string {
+	return fmt.Sprintf("%s\x00%d\x00%s", tag, len(values), strings.Join(values, "\x00"))
+}
+
+// cachedTagged returns the cached list of tagged services for the given tag and values, if any, along with the
+// generation of the instances of the singletons, needed to cache a new list.
+func (s *singletons) cachedTagged(key string) ([]interface{}, bool, uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	l, ok := s.tagged[key]
+	return l, ok, s.generation
+}
+
+// cacheTagged caches the given list of tagged services unless instances have been removed since the given generation.
+func (s *singletons) cacheTagged(key string, services []interface{}, generation uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.generation == generation {
+		s.tagged[key] = services
+	}
+}
+
+// flush must be called with the lock held whenever instances are removed, so the cached lists of tagged services
+// holding them are discarded.
+func (s *singletons) flush() {
+	s.generation++
+	for k := range s.tagged {
+		delete(s.tagged, k)
+	}
+}
+
+// cacheable returns if the list of tagged services on the given keys can be cached, which is the case when all of them
+// are public shared services. Lists are not cached while other services are being built or when there are observers,
+// because retrievals must be recorded in the dependency graph and notified to the observers.
+func (c *container) cacheable(keys []string) bool {
+	if len(c.loading) > 0 || len(c.notified()) > 0 {
+		return false
+	}
+
+	for _, k := range keys {
+		def := c.definition(k)
+		if def == nil || !def.Shared || def.Private || def.Kind == TagSynthetic {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainer_TagIndex(t *testing.T) {
+	t.Run("indexes keys as GetTaggedKeys", func(t *testing.T) {
+		b := newContainerBuilder()
+		b.SetValue("k1 #tag=a", 1)
+		b.SetValue("k2 #tag=b #priority=5", 2)
+		b.SetValue("k3 #tag=a #priority=5", 3)
+		b.SetValue("k4 #tag", 4)
+		b.SetValue("k5 #tag=a #abstract", 5)
+		b.AddResolver(ResolverFunc(func(b ContainerBuilder) {
+			b.SetValue("k6 #tag=b", 6)
+		}))
+		c := b.resolve()
+
+		for _, values := range [][]string{nil, {"a"}, {"b"}, {""}, {"a", "b"}, {"b", "", "c"}, {"c"}} {
+			assert.Equal(t, b.GetTaggedKeys("tag", values), append([]string{}, c.tags.keys("tag", values)...))
+		}
+		assert.Equal(t, []string{"k2", "k3", "k1", "k4", "k6"}, c.tags.keys("tag", nil))
+		assert.Equal(t, []interface{}{3, 1}, c.GetTaggedBy("tag", "a"))
+		assert.Empty(t, c.GetTaggedBy("missing"))
+	})
+
+	t.Run("caches lists of shared services", func(t *testing.T) {
+		spy := 0
+		b := newContainerBuilder()
+		b.SetFactory("s1 #shared #tag", func(c Container) interface{} {
+			spy++
+			return &spy
+		})
+		b.SetValue("s2 #shared #tag=x", 2)
+		c := b.resolve()
+
+		l1 := c.GetTaggedBy("tag")
+		l1[0] = "changed"
+		l2 := c.GetTaggedBy("tag")
+
+		assert.Equal(t, []interface{}{&spy, 2}, l2)
+		assert.Len(t, c.singletons.tagged, 1)
+		assert.Equal(t, []interface{}{2}, c.GetTaggedBy("tag", "x"))
+		assert.Equal(t, []interface{}{&spy}, c.GetTaggedBy("tag", ""))
+		assert.Len(t, c.singletons.tagged, 3)
+		assert.Equal(t, 1, spy)
+	})
+
+	t.Run("doesn't cache lists with non shared or private services", func(t *testing.T) {
+		b := newContainerBuilder()
+		b.SetValue("s #shared #tag", 1)
+		b.SetValue("n #tag", 2)
+		b.SetValue("p #shared #private #other", 3)
+		b.SetFactory("f #shared", func(c Container) interface{} {
+			return c.GetTaggedBy("tag")
+		})
+		c := b.resolve()
+
+		assert.Equal(t, []interface{}{1, 2}, c.GetTaggedBy("tag"))
+		assert.Equal(t, []interface{}{1, 2}, c.Get("f"))
+		assert.Panics(t, func() {
+			c.GetTaggedBy("other")
+		})
+		assert.Empty(t, c.singletons.tagged)
+	})
+
+	t.Run("doesn't cache lists with observers", func(t *testing.T) {
+		o := &recordingObserver{}
+		b := newContainerBuilder()
+		b.SetValue("s #shared #tag", 1)
+		c := b.resolve()
+		c.AddObserver(o)
+
+		c.GetTaggedBy("tag")
+		c.GetTaggedBy("tag")
+
+		assert.Equal(t, []string{"before s [s]", "after s 1 <nil>", "hit s"}, o.events)
+		assert.Empty(t, c.singletons.tagged)
+	})
+
+	t.Run("discards cached lists when instances are removed", func(t *testing.T) {
+		spy := 0
+		b := NewContainerBuilder()
+		b.SetFactory("s #shared #tag", func(c Container) interface{} {
+			spy++
+			return spy
+		})
+		c := b.GetContainer()
+
+		assert.Equal(t, []interface{}{1}, c.GetTaggedBy("tag"))
+		snapshot := c.Snapshot()
+		c.Reset()
+		assert.Equal(t, []interface{}{2}, c.GetTaggedBy("tag"))
+		c.Reset("s")
+		assert.Equal(t, []interface{}{3}, c.GetTaggedBy("tag"))
+		c.Restore(snapshot)
+		assert.Equal(t, []interface{}{1}, c.GetTaggedBy("tag"))
+		restore := c.Override("s", 10)
+		assert.Equal(t, []interface{}{10}, c.GetTaggedBy("tag"))
+		restore()
+		assert.Equal(t, []interface{}{4}, c.GetTaggedBy("tag"))
+	})
+}
+
+// newBenchmarkContainer returns a container with the given number of services, a tenth of them tagged.
+func newBenchmarkContainer(services int, shared bool) *container {
+	b := newContainerBuilder()
+	for i := 0; i < services; i++ {
+		key := fmt.Sprintf("service.%d", i)
+		if i%10 == 0 {
+			key += fmt.Sprintf(" #listener=%d #priority=%d", i%3, i%7)
+		}
+		if shared {
+			key += " #shared"
+		}
+		b.SetValue(key, i)
+	}
+
+	return b.resolve()
+}
+
+func BenchmarkContainerBuilder_GetTaggedKeys(b *testing.B) {
+	c := newBenchmarkContainer(5000, true)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.builder.GetTaggedKeys("listener", []string{"1"})
+	}
+}
+
+func BenchmarkContainer_GetTaggedBy(b *testing.B) {
+	for _, shared := range []bool{false, true} {
+		b.Run(fmt.Sprintf("shared=%t", shared), func(b *testing.B) {
+			c := newBenchmarkContainer(5000, shared)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.GetTaggedBy("listener", "1")
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TagType is the type of the values of a tag declared with a TagSchema.
+type TagType int
+
+// Types of tag values. String tags accept any value.
+const (
+	TagTypeString TagType = iota
+	TagTypeInt
+	TagTypeBool
+	TagTypeDuration
+	TagTypeEnum
+)
+
+// String returns the name of the tag type.
+func (t TagType) String() string {
+	switch t {
+	case TagTypeInt:
+		return "int"
+	case TagTypeBool:
+		return "bool"
+	case TagTypeDuration:
+		return "duration"
+	case TagTypeEnum:
+		return "enum"
+	}
+	return "string"
+}
+
+// TagSchema declares the type of the values of a custom tag, so invalid values make the builder setters panic when
+// definitions are registered instead of failing later. Values are the allowed ones of enum tags, Default is the value
+// returned by the typed getters of definitions without the tag or with an empty value, and Validate, if not nil, is
+// called with every non empty value after checking its type. Boolean tags with an empty value are true, as the reserved
+// ones are.
+type TagSchema struct {
+	Name     string
+	Type     TagType
+	Values   []string
+	Default  string
+	Validate func(value string) error
+}
+
+// validate returns an error if the given tag value is not valid for current schema.
+func (s TagSchema) validate(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	var err error
+	switch s.Type {
+	case TagTypeInt:
+		if _, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s tag value '%s' is not a valid number", s.Name, value)
+		}
+	case TagTypeBool:
+		if _, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s tag value '%s' is not a valid boolean", s.Name, value)
+		}
+	case TagTypeDuration:
+		if _, err = time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%s tag value '%s' is not a valid duration", s.Name, value)
+		}
+	case TagTypeEnum:
+		if !inStrings(value, s.Values) {
+			return fmt.Errorf("%s tag value '%s' is not one of %v", s.Name, value, s.Values)
+		}
+	}
+
+	if s.Validate != nil {
+		if err = s.Validate(value); err != nil {
+			return fmt.Errorf("%s tag value '%s' is not valid: %s", s.Name, value, err)
+		}
+	}
+
+	return nil
+}
+
+// reservedTags are the tags handled by the package, whose schemas can't be registered.
+var reservedTags = []string{TagShared, TagPrivate, TagPriority, TagInject, TagValue, TagAlias, TagFactory, TagAbstract,
+	TagSynthetic, TagOverride, TagDeprecated, TagWhen, TagIfMissing, TagIfPresent}
+
+// tagSchemas is the registry of custom tag schemas of a builder indexed by tag name, shared with its definitions.
+type tagSchemas struct {
+	schemas map[string]TagSchema
+	lock    sync.RWMutex
+}
+
+// RegisterTag registers the schemas of custom tags for the definitions of the builder, replacing the ones registered
+// before with the same name. Schemas must be registered before the definitions using their tags:
+//
+//	b.RegisterTag(di.TagSchema{Name: "timeout", Type: di.TagTypeDuration, Default: "5s"})
+//	b.RegisterTag(di.TagSchema{Name: "channel", Type: di.TagTypeEnum, Values: []string{"email", "sms"}})
+//
+// It panics if the builder is resolved, if a tag is reserved, if an enum tag has no values or if the default value is
+// not valid.
+func (c *containerBuilder) RegisterTag(schemas ...TagSchema) {
+	c.panicIfResolved()
+
+	for _, s := range schemas {
+		if s.Name == "" || inStrings(s.Name, reservedTags) || strings.HasPrefix(s.Name, injectOverridePrefix) {
+			panic(fmt.Sprintf("tag '%s' is reserved and its schema can't be registered", s.Name))
+		}
+		if s.Type == TagTypeEnum && len(s.Values) == 0 {
+			panic(fmt.Sprintf("enum tag '%s' must declare its values", s.Name))
+		}
+		if err := s.validate(s.Default); err != nil {
+			panic(fmt.Sprintf("invalid default value of tag schema: %s", err))
+		}
+	}
+
+	c.schemas.lock.Lock()
+	defer c.schemas.lock.Unlock()
+
+	for _, s := range schemas {
+		c.schemas.schemas[s.Name] = s
+	}
+}
+
+// lookup returns the schema registered for the given tag, if any. Definitions created out of a builder have no
+// registry, so their tags have no schema.
+func (r *tagSchemas) lookup(tag string) (TagSchema, bool) {
+	if r == nil {
+		return TagSchema{}, false
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	s, ok := r.schemas[tag]
+	return s, ok
+}
+
+// validate returns an error if the given value is not valid for the schema registered for the tag, if any.
+func (r *tagSchemas) validate(tag, value string) error {
+	if s, ok := r.lookup(tag); ok {
+		return s.validate(value)
+	}
+	return nil
+}
+
+// typedTag returns the first value of the given tag, or the default value of its schema if the definition doesn't
+// have the tag or its value is empty.
+func (d *definition) typedTag(tag string) string {
+	v := d.GetTag(tag)
+	if v != "" {
+		return v
+	}
+
+	if s, ok := d.schemas.lookup(tag); ok {
+		return s.Default
+	}
+	return ""
+}
+
+// TagString returns the value of the given tag, or the default value of its schema if the definition doesn't have the
+// tag or its value is empty.
+func (d *definition) TagString(tag string) string {
+	return d.typedTag(tag)
+}
+
+// TagInt returns the value of the given tag as an integer, as TagString does, or zero if it's not a valid number.
+func (d *definition) TagInt(tag string) int {
+	i, _ := strconv.Atoi(d.typedTag(tag))
+	return i
+}
+
+// TagBool returns the value of the given tag as a boolean, as TagString does. Tags with an empty value and without
+// default value are true, while missing or not valid ones are false.
+func (d *definition) TagBool(tag string) bool {
+	v := d.typedTag(tag)
+	if v == "" {
+		return d.HasTag(tag)
+	}
+
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// TagDuration returns the value of the given tag as a duration, as TagString does, or zero if it's not a valid
+// duration.
+func (d *definition) TagDuration(tag string) time.Duration {
+	t, _ := time.ParseDuration(d.typedTag(tag))
+	return t
+}
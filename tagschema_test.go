@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterTag(t *testing.T) {
+	t.Run("validates values on registration", func(t *testing.T) {
+		schemas := []TagSchema{
+			TagSchema{Name: "test.timeout", Type: TagTypeDuration, Default: "5s"},
+			TagSchema{Name: "test.retries", Type: TagTypeInt},
+			TagSchema{Name: "test.async", Type: TagTypeBool},
+			TagSchema{Name: "test.channel", Type: TagTypeEnum, Values: []string{"email", "sms"}},
+			TagSchema{Name: "test.path", Validate: func(v string) error {
+				if !strings.HasPrefix(v, "/") {
+					return errors.New("must be absolute")
+				}
+				return nil
+			}},
+		}
+
+		for key, err := range map[string]string{
+			"k #test.timeout=abc":                   "test.timeout tag value 'abc' is not a valid duration for key 'k'",
+			"k #test.retries=1.5":                   "test.retries tag value '1.5' is not a valid number for key 'k'",
+			"k #test.async=on":                      "test.async tag value 'on' is not a valid boolean for key 'k'",
+			"k #test.channel=push":                  "test.channel tag value 'push' is not one of [email sms] for key 'k'",
+			"k #test.channel=email #test.channel=x": "test.channel tag value 'x' is not one of [email sms] for key 'k'",
+			"k #test.path=tmp":                      "test.path tag value 'tmp' is not valid: must be absolute for key 'k'",
+		} {
+			b := NewContainerBuilder()
+			b.RegisterTag(schemas...)
+			assert.PanicsWithValue(t, err, func() {
+				b.SetValue(key, 1)
+			}, key)
+		}
+
+		b := NewContainerBuilder()
+		b.RegisterTag(schemas...)
+		assert.PanicsWithValue(t, "test.retries tag value 'x' is not a valid number for key 'k'", func() {
+			b.SetValue("k", 1, map[string]string{"test.retries": "x"})
+		})
+		assert.PanicsWithValue(t, "test.channel tag value 'x' is not one of [email sms] for key 'k'", func() {
+			b.SetAll(Binding{Key: "k #value", Target: 1, TagValues: map[string][]string{"test.channel": {"sms", "x"}}})
+		})
+		assert.NotPanics(t, func() {
+			b.SetValue("ok #test.timeout=1m #test.retries=3 #test.async #test.channel=sms;priority=2 #test.path=/tmp", 1)
+			b.SetValue("empty #test.timeout #test.retries= #test.channel", 1)
+		})
+	})
+
+	t.Run("validates values only on the builder registering the schema", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.RegisterTag(TagSchema{Name: "test.retries", Type: TagTypeInt, Default: "3"})
+		d := b.SetValue("k", 1)
+		assert.Equal(t, 3, d.TagInt("test.retries"))
+
+		other := newContainerBuilder()
+		assert.NotPanics(t, func() {
+			other.SetValue("k #test.retries=x", 1)
+		})
+		assert.Equal(t, 0, other.SetValue("k", 1).TagInt("test.retries"))
+		_, ok := other.schemas.lookup("test.retries")
+		assert.False(t, ok)
+	})
+
+	t.Run("panics once the builder is resolved", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.GetContainer()
+
+		assert.PanicsWithValue(t, "container is resolved and new items can not be set", func() {
+			b.RegisterTag(TagSchema{Name: "test.retries", Type: TagTypeInt})
+		})
+	})
+
+	t.Run("panics on invalid schemas", func(t *testing.T) {
+		for _, data := range []struct {
+			schema TagSchema
+			err    string
+		}{
+			{TagSchema{Name: TagShared, Type: TagTypeBool}, "tag 'shared' is reserved and its schema can't be registered"},
+			{TagSchema{Name: "inject.Field"}, "tag 'inject.Field' is reserved and its schema can't be registered"},
+			{TagSchema{Name: ""}, "tag '' is reserved and its schema can't be registered"},
+			{TagSchema{Name: "test.enum", Type: TagTypeEnum}, "enum tag 'test.enum' must declare its values"},
+			{TagSchema{Name: "test.int", Type: TagTypeInt, Default: "x"},
+				"invalid default value of tag schema: test.int tag value 'x' is not a valid number"},
+		} {
+			assert.PanicsWithValue(t, data.err, func() {
+				NewContainerBuilder().RegisterTag(data.schema)
+			})
+		}
+	})
+}
+
+func TestDefinition_TypedTags(t *testing.T) {
+	b := NewContainerBuilder()
+	b.RegisterTag(
+		TagSchema{Name: "test.timeout", Type: TagTypeDuration, Default: "5s"},
+		TagSchema{Name: "test.retries", Type: TagTypeInt, Default: "3"},
+		TagSchema{Name: "test.async", Type: TagTypeBool, Default: "false"},
+		TagSchema{Name: "test.channel", Type: TagTypeEnum, Values: []string{"email", "sms"}, Default: "email"},
+	)
+	set := b.SetValue("set #test.timeout=1m #test.retries=5 #test.async=true #test.channel=sms #other=7 #flag", 1)
+	unset := b.SetValue("unset #test.timeout #test.async", 1)
+
+	t.Run("returns typed values", func(t *testing.T) {
+		assert.Equal(t, time.Minute, set.TagDuration("test.timeout"))
+		assert.Equal(t, 5, set.TagInt("test.retries"))
+		assert.True(t, set.TagBool("test.async"))
+		assert.Equal(t, "sms", set.TagString("test.channel"))
+		assert.Equal(t, 7, set.TagInt("other"))
+		assert.True(t, set.TagBool("flag"))
+		assert.True(t, set.TagBool(TagValue))
+	})
+
+	t.Run("returns defaults of missing or empty tags", func(t *testing.T) {
+		assert.Equal(t, 5*time.Second, unset.TagDuration("test.timeout"))
+		assert.Equal(t, 3, unset.TagInt("test.retries"))
+		assert.False(t, unset.TagBool("test.async"))
+		assert.Equal(t, "email", unset.TagString("test.channel"))
+	})
+
+	t.Run("returns zero values of missing or invalid tags without schema", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), set.TagDuration("other"))
+		assert.Equal(t, 0, set.TagInt("missing"))
+		assert.False(t, set.TagBool("missing"))
+		assert.False(t, set.TagBool("other"))
+		assert.Equal(t, "", set.TagString("missing"))
+	})
+}
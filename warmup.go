@@ -0,0 +1,402 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// WarmupOptions configures which services are built by Warmup. If Tag is given, only the services with the tag, and
+// one of the Values if given, are built. If Dry is true, built shared instances are removed afterwards as MustBuild does.
+type WarmupOptions struct {
+	Tag    string
+	Values []string
+	Dry    bool
+}
+
+// ServiceProfile is the construction cost of a single service during a warmup. Inclusive time and allocations include
+// the ones of its dependencies, while Exclusive time doesn't. Depth is the length of the longest chain of dependencies
+// built below the service. Error contains the panic message if the service couldn't be built.
+type ServiceProfile struct {
+	Key       string        `json:"key"`
+	Builds    int           `json:"builds"`
+	Inclusive time.Duration `json:"inclusive_ns"`
+	Exclusive time.Duration `json:"exclusive_ns"`
+	Allocs    uint64        `json:"allocs"`
+	Depth     int           `json:"depth"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// WarmupReport is the result of a warmup. Services are sorted by inclusive time, the most expensive first, and the
+// critical path is the chain of dependencies with the highest construction time.
+type WarmupReport struct {
+	Total        time.Duration    `json:"total_ns"`
+	Services     []ServiceProfile `json:"services"`
+	CriticalPath []string         `json:"critical_path"`
+}
+
+// profileFrame is a service being built while profiling.
+type profileFrame struct {
+	key       string
+	start     time.Time
+	mallocs   uint64
+	childTime time.Duration
+	height    int
+	path      []string
+	pathTime  time.Duration
+}
+
+// profiler is an Observer measuring the construction cost of services. It is not safe for concurrent builds.
+type profiler struct {
+	stack    []*profileFrame
+	profiles map[string]*ServiceProfile
+	critical []string
+	slowest  time.Duration
+}
+
+// mallocs returns the cumulative count of heap allocations.
+func mallocs() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.Mallocs
+}
+
+// OnBeforeBuild pushes a new frame for the service.
+func (p *profiler) OnBeforeBuild(key string, _ []string) {
+	p.stack = append(p.stack, &profileFrame{key: key, start: time.Now(), mallocs: mallocs()})
+}
+
+// OnAfterBuild pops the frame of the service, accumulates its profile and propagates its cost to the parent frame.
+func (p *profiler) OnAfterBuild(key string, _ interface{}, duration time.Duration, err error) {
+	allocs := mallocs()
+	f := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	sp, ok := p.profiles[key]
+	if !ok {
+		sp = &ServiceProfile{Key: key}
+		p.profiles[key] = sp
+	}
+	sp.Builds++
+	sp.Inclusive += duration
+	sp.Exclusive += duration - f.childTime
+	sp.Allocs += allocs - f.mallocs
+	if f.height > sp.Depth {
+		sp.Depth = f.height
+	}
+	if err != nil && sp.Error == "" {
+		sp.Error = err.Error()
+	}
+
+	path := append([]string{key}, f.path...)
+	if len(p.stack) == 0 {
+		if duration > p.slowest || p.critical == nil {
+			p.slowest, p.critical = duration, path
+		}
+		return
+	}
+
+	parent := p.stack[len(p.stack)-1]
+	parent.childTime += duration
+	if f.height+1 > parent.height {
+		parent.height = f.height + 1
+	}
+	if duration > parent.pathTime || parent.path == nil {
+		parent.pathTime, parent.path = duration, path
+	}
+}
+
+// OnCacheHit does nothing, cached services have no construction cost.
+func (p *profiler) OnCacheHit(string) {}
+
+// Warmup builds all the public services, or the tagged ones, in key order and returns a report with the construction
+// cost of each service and its dependencies. Panics building a service are recovered and reported on its profile.
+// Services retrieved concurrently from other goroutines are not profiled, as the profiler only observes the services
+// built by Warmup.
+func (c *container) Warmup(opts WarmupOptions) *WarmupReport {
+	keys := make([]string, 0, len(c.builder.definitions))
+	if opts.Tag != "" {
+		keys = append(keys, c.builder.GetTaggedKeys(opts.Tag, opts.Values)...)
+	} else {
+		for k := range c.builder.definitions {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	p := &profiler{profiles: make(map[string]*ServiceProfile)}
+	sc := c.scope(p)
+
+	start := time.Now()
+	for _, k := range keys {
+		d := c.builder.definitions[k]
+		if d.Private || d.Abstract || d.Kind == TagSynthetic {
+			continue
+		}
+		sc.warmup(k)
+	}
+
+	report := &WarmupReport{
+		Total:        time.Since(start),
+		Services:     make([]ServiceProfile, 0, len(p.profiles)),
+		CriticalPath: p.critical,
+	}
+	for _, sp := range p.profiles {
+		report.Services = append(report.Services, *sp)
+	}
+	sort.Slice(report.Services, func(i, j int) bool {
+		a, b := report.Services[i], report.Services[j]
+		if a.Inclusive != b.Inclusive {
+			return a.Inclusive > b.Inclusive
+		}
+		return a.Key < b.Key
+	})
+
+	if opts.Dry {
+		c.Reset()
+	}
+
+	return report
+}
+
+// warmup retrieves the service on the given key recovering from any panic, which is already reported by the profiler.
+func (c *container) warmup(key string) {
+	defer func() {
+		_ = recover()
+	}()
+
+	_ = c.Get(key)
+}
+
+// Text returns the report as a human-readable table.
+func (r *WarmupReport) Text() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Total: %s\n", r.Total)
+	fmt.Fprintf(&b, "Critical path: %s\n\n", strings.Join(r.CriticalPath, " -> "))
+
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tBUILDS\tINCLUSIVE\tEXCLUSIVE\tALLOCS\tDEPTH\tERROR")
+	for _, s := range r.Services {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%d\t%s\n", s.Key, s.Builds, s.Inclusive, s.Exclusive, s.Allocs, s.Depth,
+			orDash(s.Error))
+	}
+	_ = w.Flush()
+
+	return b.String()
+}
+
+// JSON returns the report encoded as indented JSON, with durations in nanoseconds.
+func (r *WarmupReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// BuildError is the error produced building the service on Key. Chain is the chain of services being built when the
+// error was produced, if known, starting with Key.
+type BuildError struct {
+	Key   string
+	Chain []string
+	Err   error
+}
+
+// Error returns the error message prefixed with the service key and the chain of services, if any.
+func (e BuildError) Error() string {
+	if len(e.Chain) > 1 {
+		return fmt.Sprintf("service with key '%s' failed to build at %s: %s", e.Key, strings.Join(e.Chain, " -> "), e.Err)
+	}
+	return fmt.Sprintf("service with key '%s' failed to build: %s", e.Key, e.Err)
+}
+
+// BuildErrors aggregates the errors produced building several services.
+type BuildErrors []BuildError
+
+// Error returns all the error messages, one per line.
+func (e BuildErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("%d services failed to build:\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// WarmupParallel builds all the shared services, including the private ones, with the given number of concurrent
+// workers. A service is only built once the shared services it depends on, according to the dependency graph, have
+// been built. Dependencies unknown by the graph are built by the worker which needs them first, and the container
+// ensures each shared service is only built once. Circular references between shared services unknown by the graph
+// may lock the workers building them, so calling Warmup beforehand to observe the dependencies is advised. No more
+// services are scheduled once the context is cancelled, and its error is returned after waiting for the running builds.
+// Otherwise, the panics building services are returned as BuildErrors sorted by key.
+func (c *container) WarmupParallel(ctx context.Context, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	type result struct {
+		key string
+		err *BuildError
+	}
+
+	jobs := make(chan string)
+	results := make(chan result)
+	defer close(jobs)
+	for i := 0; i < workers; i++ {
+		go func(u *container) {
+			for k := range jobs {
+				results <- result{key: k, err: u.build(k)}
+			}
+		}(c.unseal())
+	}
+
+	deps, dependents := c.sharedDependencies()
+	ready := make([]string, 0, len(deps))
+	queued := make(map[string]bool, len(deps))
+	queue := func(keys ...string) {
+		sort.Strings(keys)
+		for _, k := range keys {
+			if !queued[k] {
+				queued[k] = true
+				ready = append(ready, k)
+			}
+		}
+	}
+
+	pending := make(map[string]int, len(deps))
+	for k, d := range deps {
+		pending[k] = len(d)
+		if len(d) == 0 {
+			queue(k)
+		}
+	}
+
+	errs := make(BuildErrors, 0)
+	running := 0
+	cancelled := false
+	for {
+		cancelled = cancelled || ctx.Err() != nil
+		if running == 0 && (cancelled || len(pending) == 0) {
+			break
+		}
+
+		// services in a dependency cycle never get ready, so they are scheduled one by one to report the circular
+		// reference, because building them concurrently would lock each other.
+		if !cancelled && len(ready) == 0 && running == 0 {
+			left := make([]string, 0, len(pending))
+			for k := range pending {
+				left = append(left, k)
+			}
+			sort.Strings(left)
+			queue(left[0])
+		}
+
+		var next chan string
+		var done <-chan struct{}
+		key := ""
+		if !cancelled {
+			done = ctx.Done()
+			if len(ready) > 0 {
+				next, key = jobs, ready[0]
+			}
+		}
+
+		select {
+		case next <- key:
+			ready = ready[1:]
+			delete(pending, key)
+			running++
+		case r := <-results:
+			running--
+			if r.err != nil {
+				errs = append(errs, *r.err)
+			}
+			for _, d := range dependents[r.key] {
+				if _, ok := pending[d]; ok {
+					if pending[d]--; pending[d] <= 0 {
+						queue(d)
+					}
+				}
+			}
+		case <-done:
+			cancelled = true
+		}
+	}
+
+	if cancelled {
+		return ctx.Err()
+	}
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].Key < errs[j].Key
+		})
+		return errs
+	}
+
+	return nil
+}
+
+// sharedDependencies returns, for each shared service which can be built, the shared services it depends on and the
+// ones depending on it. Dependencies are found in the graph, going through the non shared services in between.
+func (c *container) sharedDependencies() (deps, dependents map[string][]string) {
+	defs := c.builder.definitions
+	buildable := func(k string) bool {
+		d, ok := defs[k]
+		return ok && d.Shared && !d.Abstract && d.Kind != TagSynthetic
+	}
+
+	adjacency := make(map[string][]string)
+	for _, e := range c.Graph().Edges {
+		adjacency[e.From] = append(adjacency[e.From], e.To)
+	}
+
+	deps = make(map[string][]string)
+	dependents = make(map[string][]string)
+	for k := range defs {
+		if !buildable(k) {
+			continue
+		}
+
+		deps[k] = make([]string, 0)
+		visited := map[string]bool{k: true}
+		stack := append([]string(nil), adjacency[k]...)
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+
+			if buildable(n) {
+				deps[k] = append(deps[k], n)
+				dependents[n] = append(dependents[n], k)
+				continue
+			}
+			stack = append(stack, adjacency[n]...)
+		}
+	}
+
+	return deps, dependents
+}
+
+// build retrieves the service on the given key and returns the panic produced building it as error, if any.
+func (c *container) build(key string) (err *BuildError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &BuildError{Key: key, Err: fmt.Errorf("%v", r)}
+		}
+	}()
+
+	_ = c.Get(key)
+
+	return nil
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2021 Santiago Garcia <sangarbe@gmail.com>.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package di
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newWarmupContainer() *container {
+	sleep := func(d time.Duration, deps ...string) func(Container) interface{} {
+		return func(c Container) interface{} {
+			for _, k := range deps {
+				c.Get(k)
+			}
+			time.Sleep(d)
+			return &d
+		}
+	}
+
+	b := newContainerBuilder()
+	b.SetFactory("db #shared #private", sleep(20*time.Millisecond))
+	b.SetFactory("config #shared", sleep(time.Millisecond))
+	b.SetFactory("repo #tag", sleep(time.Millisecond, "db", "config"))
+	b.SetFactory("service #tag", sleep(time.Millisecond, "repo", "config"))
+	b.SetFactory("bad", func(c Container) interface{} { return c.Get("config").(int) })
+
+	return b.resolve()
+}
+
+func TestContainer_Warmup(t *testing.T) {
+	t.Run("profiles all services", func(t *testing.T) {
+		c := newWarmupContainer()
+		r := c.Warmup(WarmupOptions{})
+
+		profiles := make(map[string]ServiceProfile)
+		for _, s := range r.Services {
+			profiles[s.Key] = s
+		}
+
+		assert.Len(t, r.Services, 5)
+		assert.Equal(t, "repo", r.Services[0].Key)
+		assert.Equal(t, []string{"repo", "db"}, r.CriticalPath)
+		assert.Equal(t, 1, profiles["db"].Builds)
+		assert.Equal(t, 1, profiles["config"].Builds)
+		assert.Equal(t, 2, profiles["repo"].Builds)
+		assert.Equal(t, 0, profiles["db"].Depth)
+		assert.Equal(t, 1, profiles["repo"].Depth)
+		assert.Equal(t, 1, profiles["service"].Depth)
+		assert.True(t, profiles["repo"].Inclusive >= 20*time.Millisecond)
+		assert.True(t, profiles["repo"].Exclusive < profiles["repo"].Inclusive)
+		assert.True(t, profiles["service"].Exclusive < 20*time.Millisecond)
+		assert.Equal(t, "interface conversion: interface {} is *time.Duration, not int", profiles["bad"].Error)
+		assert.True(t, r.Total >= 20*time.Millisecond)
+		assert.Len(t, c.instances, 2)
+		assert.Empty(t, c.notified())
+	})
+
+	t.Run("profiles tagged services", func(t *testing.T) {
+		c := newWarmupContainer()
+		r := c.Warmup(WarmupOptions{Tag: "tag", Values: []string{""}, Dry: true})
+
+		keys := make([]string, 0)
+		for _, s := range r.Services {
+			keys = append(keys, s.Key)
+		}
+
+		assert.ElementsMatch(t, []string{"db", "config", "repo", "service"}, keys)
+		assert.Empty(t, c.instances)
+	})
+}
+
+func TestWarmupReport_Export(t *testing.T) {
+	r := &WarmupReport{
+		Total: 3 * time.Millisecond,
+		Services: []ServiceProfile{
+			{Key: "a", Builds: 1, Inclusive: 3 * time.Millisecond, Exclusive: time.Millisecond, Allocs: 10, Depth: 1},
+			{Key: "b", Builds: 2, Inclusive: 2 * time.Millisecond, Exclusive: 2 * time.Millisecond, Allocs: 5, Error: "failed"},
+		},
+		CriticalPath: []string{"a", "b"},
+	}
+
+	t.Run("exports to text", func(t *testing.T) {
+		expected := `Total: 3ms
+Critical path: a -> b
+
+KEY  BUILDS  INCLUSIVE  EXCLUSIVE  ALLOCS  DEPTH  ERROR
+a    1       3ms        1ms        10      1      -
+b    2       2ms        2ms        5       0      failed
+`
+		assert.Equal(t, expected, r.Text())
+	})
+
+	t.Run("exports to JSON", func(t *testing.T) {
+		data, err := r.JSON()
+		assert.Nil(t, err)
+
+		decoded := &WarmupReport{}
+		assert.Nil(t, json.Unmarshal(data, decoded))
+		assert.Equal(t, r, decoded)
+		assert.Contains(t, string(data), `"inclusive_ns": 3000000`)
+	})
+}
+
+func TestContainer_WarmupParallel(t *testing.T) {
+	newContainer := func(order *[]string, lock *sync.Mutex) *container {
+		build := func(key string, deps ...string) func(Container) interface{} {
+			return func(c Container) interface{} {
+				for _, k := range deps {
+					c.Get(k)
+				}
+				time.Sleep(20 * time.Millisecond)
+				lock.Lock()
+				*order = append(*order, key)
+				lock.Unlock()
+				return &key
+			}
+		}
+
+		b := newContainerBuilder()
+		b.SetFactory("a #shared", build("a"))
+		b.SetFactory("b #shared #private", build("b"))
+		b.SetFactory("c #shared", build("c"))
+		b.SetFactory("d #shared", build("d"))
+		b.SetFactory("e", build("e"))
+		b.SetFactory("f #shared", build("f", "a", "e"))
+		b.SetFactory("g #shared", build("g", "f"))
+
+		c := b.resolve()
+		c.Get("g")
+		lock.Lock()
+		*order = (*order)[:0]
+		lock.Unlock()
+		c.instances = make(map[string]interface{})
+
+		return c
+	}
+
+	t.Run("builds shared services concurrently respecting dependencies", func(t *testing.T) {
+		order := make([]string, 0)
+		lock := &sync.Mutex{}
+		c := newContainer(&order, lock)
+
+		start := time.Now()
+		err := c.WarmupParallel(context.Background(), 4)
+
+		assert.Nil(t, err)
+		assert.True(t, time.Since(start) < 120*time.Millisecond)
+		assert.Len(t, c.instances, 6)
+		assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, order[:4])
+		assert.Equal(t, []string{"e", "f", "g"}, order[4:])
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		order := make([]string, 0)
+		lock := &sync.Mutex{}
+		c := newContainer(&order, lock)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := c.WarmupParallel(ctx, 2)
+
+		assert.Equal(t, context.Canceled, err)
+		assert.Empty(t, c.instances)
+	})
+
+	t.Run("returns aggregated errors", func(t *testing.T) {
+		b := NewContainerBuilder()
+		b.SetFactory("ok #shared", dummyFactory)
+		b.SetFactory("bad #shared", func(c Container) interface{} { return c.Get("ok").(string) })
+		b.SetFactory("s1 #shared", func(c Container) interface{} { return c.Get("s2") })
+		b.SetFactory("s2 #shared", func(c Container) interface{} { return c.Get("s1") })
+		c := b.GetContainer()
+
+		err := c.WarmupParallel(context.Background(), 0)
+
+		assert.Equal(t, BuildErrors{
+			{Key: "bad", Err: errors.New("interface conversion: interface {} is int, not string")},
+			{Key: "s1", Err: errors.New("circular reference found while building service 's1' at service 's2'")},
+			{Key: "s2", Err: errors.New("circular reference found while building service 's2' at service 's1'")},
+		}, err)
+		assert.Equal(t, "3 services failed to build:\n"+
+			"service with key 'bad' failed to build: interface conversion: interface {} is int, not string\n"+
+			"service with key 's1' failed to build: circular reference found while building service 's1' at service 's2'\n"+
+			"service with key 's2' failed to build: circular reference found while building service 's2' at service 's1'",
+			err.Error())
+	})
+}